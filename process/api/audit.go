@@ -0,0 +1,200 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+)
+
+// recordAuditRequest is the body of an audit recording by a dialer. Type is
+// dequeued, disposition or skipped, e.g. a lead on a do not call list.
+type recordAuditRequest struct {
+	Events []struct {
+		Type       string    `json:"type"`
+		Reason     string    `json:"reason"`
+		CampaignID string    `json:"campaign_id"`
+		ListNumber string    `json:"list_number"`
+		LeadID     string    `json:"lead_id"`
+		Detail     string    `json:"detail"`
+		At         time.Time `json:"at"`
+	} `json:"events"`
+}
+
+// dialerAuditTypes are the event types dialers may record, injections are
+// recorded by the hopper itself
+var dialerAuditTypes = map[string]bool{
+	db.AuditDequeued:    true,
+	db.AuditDisposition: true,
+	db.AuditSkipped:     true,
+}
+
+// leadAuditResponse is the audit trail of a lead on a day
+type leadAuditResponse struct {
+	LeadID     string          `json:"lead_id"`
+	ListNumber string          `json:"list_number"`
+	CampaignID string          `json:"campaign_id"`
+	Day        string          `json:"day"`
+	Events     []db.AuditEvent `json:"events"`
+}
+
+// handleRecordAudit appends the dequeues, dispositions and skips reported by
+// a dialer to the audit history of a workspace
+func (s *Server) handleRecordAudit(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+
+	var req recordAuditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	events := make([]db.AuditEvent, 0, len(req.Events))
+	for _, event := range req.Events {
+		if !dialerAuditTypes[event.Type] {
+			writeError(w, http.StatusBadRequest, "type must be dequeued, disposition or skipped")
+			return
+		}
+
+		if event.LeadID == "" || event.ListNumber == "" {
+			writeError(w, http.StatusBadRequest, "lead_id and list_number are required")
+			return
+		}
+
+		if event.Type != db.AuditDequeued && event.Reason == "" {
+			writeError(w, http.StatusBadRequest, "reason is required for dispositions and skips")
+			return
+		}
+
+		events = append(events, db.AuditEvent{
+			WorkspaceID: workspaceID,
+			At:          event.At,
+			Type:        event.Type,
+			Reason:      event.Reason,
+			CampaignID:  event.CampaignID,
+			ListNumber:  event.ListNumber,
+			LeadID:      event.LeadID,
+			Detail:      event.Detail,
+		})
+	}
+
+	if err := db.RecordAuditEvents(r.Context(), events...); err != nil {
+		logger.Error("failed to record audit events", logging.WorkspaceID, workspaceID, "count", len(events), "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to record audit events")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]int{"recorded": len(events)})
+}
+
+// handleGetAudit lists the audit events of a workspace on ?day= (UTC, today
+// by default), optionally only those of ?type= or ?lead=
+func (s *Server) handleGetAudit(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+
+	day, ok := auditDay(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := db.GetAuditEvents(r.Context(), workspaceID, day)
+	if err != nil {
+		logger.Error("failed to get audit events", logging.WorkspaceID, workspaceID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to get audit events")
+		return
+	}
+
+	eventType, leadID := r.URL.Query().Get("type"), r.URL.Query().Get("lead")
+	filtered := []db.AuditEvent{}
+	for _, event := range events {
+		if (eventType == "" || event.Type == eventType) && (leadID == "" || event.LeadID == leadID) {
+			filtered = append(filtered, event)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, filtered)
+}
+
+// handleGetLeadAudit answers why a lead was or was not called on ?day= (UTC,
+// today by default): its own events and the campaign and list wide skips
+// that applied to it. The list of the lead is given by ?list=.
+func (s *Server) handleGetLeadAudit(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+	leadID := r.PathValue("lead")
+
+	listNumber := r.URL.Query().Get("list")
+	if listNumber == "" {
+		writeError(w, http.StatusBadRequest, "list is required")
+		return
+	}
+
+	day, ok := auditDay(w, r)
+	if !ok {
+		return
+	}
+
+	lists, err := db.GetListsByWorkspace(workspaceID)
+	if err != nil {
+		logger.Error("failed to get lists", logging.WorkspaceID, workspaceID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to get lead audit")
+		return
+	}
+
+	campaignID, found := "", false
+	for _, list := range lists {
+		if list.ListNumber == listNumber {
+			campaignID, found = list.CampaignID, true
+			break
+		}
+	}
+
+	if !found {
+		writeError(w, http.StatusNotFound, "list not found")
+		return
+	}
+
+	// the lead and campaign partitions hold the trail, not the whole workspace day
+	events, err := db.GetLeadAuditEvents(r.Context(), workspaceID, leadID, day)
+	if err != nil {
+		logger.Error("failed to get lead audit events", logging.WorkspaceID, workspaceID, logging.LeadID, leadID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to get lead audit")
+		return
+	}
+
+	campaignEvents, err := db.GetCampaignAuditEvents(r.Context(), workspaceID, campaignID, day)
+	if err != nil {
+		logger.Error("failed to get campaign audit events", logging.WorkspaceID, workspaceID, logging.CampaignID, campaignID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to get lead audit")
+		return
+	}
+
+	events = append(events, campaignEvents...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.After(events[j].At) })
+
+	writeJSON(w, http.StatusOK, leadAuditResponse{
+		LeadID:     leadID,
+		ListNumber: listNumber,
+		CampaignID: campaignID,
+		Day:        day.Format(time.DateOnly),
+		Events:     db.LeadAuditTrail(events, campaignID, listNumber, leadID),
+	})
+}
+
+// auditDay reads the ?day= of an audit query, writing a 400 when it is invalid
+func auditDay(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	value := r.URL.Query().Get("day")
+	if value == "" {
+		return time.Now().UTC(), true
+	}
+
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "day must be formatted as 2006-01-02")
+		return time.Time{}, false
+	}
+
+	return day, true
+}
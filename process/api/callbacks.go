@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/redis"
+	"github.com/nico-phil/process/tz"
+)
+
+// createCallbackRequest is the body of a callback creation. DueAt is either
+// RFC3339 or a wall clock time ("2006-01-02T15:04") in the lead's time zone.
+type createCallbackRequest struct {
+	CampaignID string `json:"campaign_id"`
+	ListNumber string `json:"list_number"`
+	LeadID     string `json:"lead_id"`
+	AgentID    string `json:"agent_id"`
+	DueAt      string `json:"due_at"`
+}
+
+// handleCreateCallback schedules a callback for a lead, optionally owned by an agent
+func (s *Server) handleCreateCallback(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+
+	var req createCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.CampaignID == "" || req.ListNumber == "" || req.LeadID == "" || req.DueAt == "" {
+		writeError(w, http.StatusBadRequest, "campaign_id, list_number, lead_id and due_at are required")
+		return
+	}
+
+	lead, err := db.GetLeadByID(r.Context(), workspaceID, req.ListNumber, req.LeadID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "lead not found")
+		return
+	}
+
+	dueAt, err := tz.ParseLocalTimeAt(s.zipCodeCache, lead.ZipCode, req.DueAt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	callback, err := db.CreateCallback(db.Callback{
+		WorkspaceID: workspaceID,
+		CampaignID:  req.CampaignID,
+		ListNumber:  req.ListNumber,
+		LeadID:      req.LeadID,
+		AgentID:     req.AgentID,
+		DueAt:       dueAt.UTC(),
+	})
+	if err != nil {
+		logger.Error("failed to create callback", logging.WorkspaceID, workspaceID, logging.LeadID, req.LeadID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to create callback")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, callback)
+}
+
+// handleCancelCallback cancels a callback, removing it from the queue if it was already injected
+func (s *Server) handleCancelCallback(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+	callbackID := r.PathValue("id")
+
+	callback, err := db.GetCallback(workspaceID, callbackID)
+	if errors.Is(err, db.ErrCallbackNotFound) {
+		writeError(w, http.StatusNotFound, "callback not found")
+		return
+	}
+
+	if err != nil {
+		logger.Error("failed to get callback", logging.WorkspaceID, workspaceID, "callback_id", callbackID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to get callback")
+		return
+	}
+
+	// a pending callback may have been queued by a cycle that failed to save its status
+	if callback.Status != db.CallbackCancelled {
+		_, err := redis.RemoveQueuedLeads(workspaceID, func(lead redis.QueuedLead) bool {
+			return lead.CallbackID == callbackID
+		})
+		if err != nil {
+			logger.Error("failed to remove queued callback", logging.WorkspaceID, workspaceID, "callback_id", callbackID, "error", err)
+		}
+	}
+
+	if err := db.UpdateCallbackStatus(r.Context(), *callback, db.CallbackCancelled); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to cancel callback")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
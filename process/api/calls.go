@@ -0,0 +1,236 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/redis"
+)
+
+// callEndRequest is the outcome of a call reported by a dialer when it ends.
+// Lead is the queue entry the call was placed for. An abandoned call is an
+// answered call no agent picked up. Disposition is written as the call status
+// of the lead, it is required unless FailureReason is set: the dial itself
+// failed and the lead is retried instead of dispositioned.
+type callEndRequest struct {
+	Lead          redis.QueuedLead `json:"lead"`
+	Answered      bool             `json:"answered"`
+	Abandoned     bool             `json:"abandoned"`
+	HandleTimeMs  int64            `json:"handle_time_ms"`
+	Disposition   string           `json:"disposition"`
+	FailureReason string           `json:"failure_reason"`
+}
+
+// callHeartbeatRequest names the lead a call in progress was placed for, its
+// in flight entry is renewed with the call lease. The body is optional.
+type callHeartbeatRequest struct {
+	LeadID string `json:"lead_id"`
+}
+
+// availableAgentsRequest is the number of agents of a workspace ready for a call
+type availableAgentsRequest struct {
+	Available *int `json:"available"`
+}
+
+// handleCallStart counts a call placed by a dialer as in progress. The call
+// keeps counting until it ends or its lease expires without a heartbeat.
+func (s *Server) handleCallStart(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+	callID := r.PathValue("call")
+
+	if err := s.rateController.TrackCallStart(workspaceID, callID); err != nil {
+		logger.Error("failed to track call start", logging.WorkspaceID, workspaceID, "call_id", callID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to start call")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCallHeartbeat renews the lease of a call in progress and, when the
+// dialer names it, the in flight entry of its lead so reconciliation does not
+// restore a lead still on a long call. A lease that already expired answers
+// 410, the dialer starts the call again.
+func (s *Server) handleCallHeartbeat(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+	callID := r.PathValue("call")
+
+	var req callHeartbeatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	err := s.rateController.TrackCallHeartbeat(workspaceID, callID)
+	if errors.Is(err, redis.ErrLeaseExpired) {
+		writeError(w, http.StatusGone, "call lease expired")
+		return
+	}
+
+	if err != nil {
+		logger.Error("failed to track call heartbeat", logging.WorkspaceID, workspaceID, "call_id", callID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to renew call")
+		return
+	}
+
+	if req.LeadID != "" {
+		if err := redis.RenewInFlightLead(workspaceID, req.LeadID); err != nil {
+			logger.Error("failed to renew in flight lead", logging.WorkspaceID, workspaceID, logging.LeadID, req.LeadID, "call_id", callID, "error", err)
+			writeError(w, http.StatusInternalServerError, "failed to renew call")
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCallEnd stops counting a call as in progress, adds its outcome to
+// the pacing stats of the workspace and to the abandon rate of its campaign,
+// and completes its lead: the disposition is written, and recorded in the
+// audit history, before the lead leaves the in flight leads, so
+// reconciliation never sees it undispositioned. A failed dial has no outcome,
+// its lead is queued for a retry, or dead lettered after too many failures,
+// before it leaves the in flight leads. Only the end that releases the call
+// lease counts the outcome or retries the dial, a dialer repeating the
+// request still gets its disposition written.
+func (s *Server) handleCallEnd(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+	callID := r.PathValue("call")
+
+	var req callEndRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Lead.CampaignID == "" || req.Lead.ListNumber == "" || req.Lead.LeadID == "" {
+		writeError(w, http.StatusBadRequest, "lead.campaign_id, lead.list_number and lead.lead_id are required")
+		return
+	}
+
+	if req.HandleTimeMs < 0 {
+		writeError(w, http.StatusBadRequest, "handle_time_ms must not be negative")
+		return
+	}
+
+	if req.Disposition == "" && req.FailureReason == "" {
+		writeError(w, http.StatusBadRequest, "disposition is required unless failure_reason is set")
+		return
+	}
+
+	ended, err := s.rateController.TrackCallEnd(workspaceID, callID)
+	if err != nil {
+		logger.Error("failed to track call end", logging.WorkspaceID, workspaceID, "call_id", callID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to end call")
+		return
+	}
+
+	if req.FailureReason != "" {
+		// the first end already queued the retry
+		if !ended {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		s.retryFailedDial(w, workspaceID, callID, req)
+		return
+	}
+
+	if ended {
+		handleTime := time.Duration(req.HandleTimeMs) * time.Millisecond
+		if err := s.rateController.TrackCallOutcome(workspaceID, req.Answered, handleTime); err != nil {
+			logger.Error("failed to track call outcome", logging.WorkspaceID, workspaceID, "call_id", callID, "error", err)
+			writeError(w, http.StatusInternalServerError, "failed to record call outcome")
+			return
+		}
+
+		if err := s.rateController.RecordDisposition(req.Lead.CampaignID, req.Answered, req.Abandoned); err != nil {
+			logger.Error("failed to record disposition", logging.WorkspaceID, workspaceID, logging.CampaignID, req.Lead.CampaignID, "call_id", callID, "error", err)
+			writeError(w, http.StatusInternalServerError, "failed to record call outcome")
+			return
+		}
+	}
+
+	if err := db.UpdateLeadStatus(workspaceID, req.Lead.ListNumber, req.Lead.LeadID, req.Disposition); err != nil {
+		logger.Error("failed to update lead status", logging.WorkspaceID, workspaceID, logging.LeadID, req.Lead.LeadID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to record disposition")
+		return
+	}
+
+	// the audit history is best effort, the disposition is already saved
+	event := db.AuditEvent{
+		WorkspaceID: workspaceID,
+		At:          time.Now(),
+		Type:        db.AuditDisposition,
+		Reason:      req.Disposition,
+		CampaignID:  req.Lead.CampaignID,
+		ListNumber:  req.Lead.ListNumber,
+		LeadID:      req.Lead.LeadID,
+		Detail:      "call " + callID,
+	}
+	if err := db.RecordAuditEvents(r.Context(), event); err != nil {
+		logger.Error("failed to record disposition audit event", logging.WorkspaceID, workspaceID, logging.LeadID, req.Lead.LeadID, "error", err)
+	}
+
+	completeInFlightLead(w, workspaceID, req.Lead.LeadID)
+}
+
+// retryFailedDial queues the lead of a failed dial for a retry and completes it
+func (s *Server) retryFailedDial(w http.ResponseWriter, workspaceID, callID string, req callEndRequest) {
+	if s.queueManager == nil {
+		writeError(w, http.StatusServiceUnavailable, "queue manager not configured")
+		return
+	}
+
+	lead := req.Lead
+	lead.WorkspaceID = workspaceID
+	if err := s.queueManager.RetryLead(lead, req.FailureReason); err != nil {
+		logger.Error("failed to retry lead", logging.WorkspaceID, workspaceID, logging.LeadID, lead.LeadID, "call_id", callID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to retry lead")
+		return
+	}
+
+	completeInFlightLead(w, workspaceID, lead.LeadID)
+}
+
+// completeInFlightLead removes a lead from the in flight leads of a workspace
+// once its call is over and answers the dialer
+func completeInFlightLead(w http.ResponseWriter, workspaceID, leadID string) {
+	if err := redis.CompleteInFlightLead(workspaceID, leadID); err != nil {
+		logger.Error("failed to complete in flight lead", logging.WorkspaceID, workspaceID, logging.LeadID, leadID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to complete lead")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSetAvailableAgents records how many agents of a workspace are ready
+// for a call, adaptive pacing sizes injections from it
+func (s *Server) handleSetAvailableAgents(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+
+	var req availableAgentsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Available == nil || *req.Available < 0 {
+		writeError(w, http.StatusBadRequest, "available must be zero or more")
+		return
+	}
+
+	if err := s.rateController.TrackAvailableAgents(workspaceID, *req.Available); err != nil {
+		logger.Error("failed to track available agents", logging.WorkspaceID, workspaceID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to set available agents")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
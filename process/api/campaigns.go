@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/nico-phil/process/cleanup"
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+)
+
+// handlePauseCampaign pauses a campaign at once, its queued leads are parked
+// until it resumes or, with ?drain=true, set back to dialable
+func (s *Server) handlePauseCampaign(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+	campaignID := r.PathValue("campaign")
+
+	drain := false
+	if value := r.URL.Query().Get("drain"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "drain must be true or false")
+			return
+		}
+		drain = parsed
+	}
+
+	result, err := cleanup.NewService().PauseCampaign(r.Context(), workspaceID, campaignID, drain)
+	if errors.Is(err, db.ErrCampaignNotFound) {
+		writeError(w, http.StatusNotFound, "campaign not found")
+		return
+	}
+
+	if err != nil {
+		logger.Error("failed to pause campaign", logging.WorkspaceID, workspaceID, logging.CampaignID, campaignID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to pause campaign")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleResumeCampaign resumes a paused campaign and queues its parked leads again
+func (s *Server) handleResumeCampaign(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+	campaignID := r.PathValue("campaign")
+
+	result, err := cleanup.NewService().ResumeCampaign(r.Context(), workspaceID, campaignID)
+	if errors.Is(err, db.ErrCampaignNotFound) {
+		writeError(w, http.StatusNotFound, "campaign not found")
+		return
+	}
+
+	if err != nil {
+		logger.Error("failed to resume campaign", logging.WorkspaceID, workspaceID, logging.CampaignID, campaignID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to resume campaign")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
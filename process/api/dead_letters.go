@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/nico-phil/process/cleanup"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/redis"
+)
+
+// deadLettersResponse is a page of the dead letter queue of a workspace
+type deadLettersResponse struct {
+	Total       int                `json:"total"`
+	DeadLetters []redis.DeadLetter `json:"dead_letters"`
+}
+
+// handleGetDeadLetters lists the dead letter queue of a workspace, newest first
+func (s *Server) handleGetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	total, err := redis.GetDeadLetterCount(workspaceID)
+	if err != nil {
+		logger.Error("failed to count dead letters", logging.WorkspaceID, workspaceID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to get dead letters")
+		return
+	}
+
+	deadLetters, err := redis.GetDeadLetters(workspaceID, max(offset, 0), limit)
+	if err != nil {
+		logger.Error("failed to get dead letters", logging.WorkspaceID, workspaceID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to get dead letters")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deadLettersResponse{Total: total, DeadLetters: deadLetters})
+}
+
+// handleReplayDeadLetters queues dead letters again, a single one when ?id= is set
+func (s *Server) handleReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+
+	replayed, err := redis.ReplayDeadLetters(workspaceID, r.URL.Query().Get("id"))
+	if err != nil {
+		logger.Error("failed to replay dead letters", logging.WorkspaceID, workspaceID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to replay dead letters")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{"replayed": replayed})
+}
+
+// handlePurgeDeadLetters deletes the dead letter queue of a workspace and
+// sets its leads back to dialable
+func (s *Server) handlePurgeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+
+	purged, reset, err := cleanup.NewService().PurgeDeadLetters(r.Context(), workspaceID)
+	if err != nil {
+		logger.Error("failed to purge dead letters", logging.WorkspaceID, workspaceID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to purge dead letters")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{"purged": purged, "reset": reset})
+}
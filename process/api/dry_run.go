@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/nico-phil/process/logging"
+)
+
+// handleDryRun reports what the next cycle would inject for a workspace
+// without injecting anything
+func (s *Server) handleDryRun(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+
+	report, err := s.queueManager.DryRunWorkspace(r.Context(), workspaceID)
+	if err != nil {
+		logger.Error("failed to dry run workspace", logging.WorkspaceID, workspaceID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to dry run workspace")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/hopper"
+	"github.com/nico-phil/process/redis"
+	"github.com/nico-phil/process/tz"
+)
+
+// dependencyTimeout bounds a single dependency check
+const dependencyTimeout = 2 * time.Second
+
+// dependencies are checked by /healthz and /readyz, the process is not ready
+// while one of them is down
+var dependencies = map[string]func(context.Context) error{
+	"cassandra": db.Ping,
+	"redis":     redis.Ping,
+}
+
+// DependencyStatus is the result of a dependency check
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ZipCodeStatus describes the zip code data lead time zones are read from
+type ZipCodeStatus struct {
+	Loaded bool           `json:"loaded"`
+	Cached int            `json:"cached"`
+	Load   *tz.LoadStatus `json:"last_load,omitempty"`
+}
+
+// HealthReport is the body of /healthz and /readyz
+type HealthReport struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+	LastCycle    *hopper.CycleStatus         `json:"last_cycle"`
+	ZipCode      ZipCodeStatus               `json:"zip_code"`
+}
+
+// handleHealthz reports the state of the process and its dependencies. It
+// answers 200 as long as the process serves requests.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.healthReport(r.Context()))
+}
+
+// handleReadyz reports the same state as /healthz and answers 503 while a
+// dependency is down
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := s.healthReport(r.Context())
+	if report.Status != "ok" {
+		writeJSON(w, http.StatusServiceUnavailable, report)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// healthReport checks every dependency concurrently and collects the status
+// of the last hopper cycle and zip code load
+func (s *Server) healthReport(ctx context.Context) HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, dependencyTimeout)
+	defer cancel()
+
+	report := HealthReport{
+		Status:       "ok",
+		Dependencies: map[string]DependencyStatus{},
+		LastCycle:    hopper.LastCycle(),
+		ZipCode: ZipCodeStatus{
+			Cached: s.zipCodeCache.Len(),
+			Load:   tz.LastLoad(),
+		},
+	}
+	report.ZipCode.Loaded = report.ZipCode.Cached > 0
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range dependencies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+			status := DependencyStatus{Status: "up", LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				status.Status = "down"
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Dependencies[name] = status
+			if err != nil {
+				report.Status = "unavailable"
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
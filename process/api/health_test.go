@@ -0,0 +1,31 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReadyzWithoutDependencies tests the process is not ready before it
+// connected to cassandra and redis, while staying healthy
+func TestReadyzWithoutDependencies(t *testing.T) {
+	handler := NewServer(nil, nil, nil).Handler()
+
+	readyz := httptest.NewRecorder()
+	handler.ServeHTTP(readyz, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, readyz.Code)
+
+	var report HealthReport
+	assert.NoError(t, json.NewDecoder(readyz.Body).Decode(&report))
+	assert.Equal(t, "unavailable", report.Status)
+	assert.Equal(t, "down", report.Dependencies["cassandra"].Status)
+	assert.Equal(t, "down", report.Dependencies["redis"].Status)
+	assert.False(t, report.ZipCode.Loaded)
+
+	healthz := httptest.NewRecorder()
+	handler.ServeHTTP(healthz, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, healthz.Code)
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/hopper"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/metrics"
+	"github.com/nico-phil/process/ratelimit"
+	"github.com/nico-phil/process/tz"
+)
+
+var logger = logging.For("api")
+
+// Server exposes the process state over HTTP
+type Server struct {
+	rateController *ratelimit.RateController
+	queueManager   *hopper.QueueManager
+	zipCodeCache   *tz.ZipCodeCache
+	mux            *http.ServeMux
+}
+
+// NewServer creates a new api server
+func NewServer(rateController *ratelimit.RateController, queueManager *hopper.QueueManager, zipCodeCache *tz.ZipCodeCache) *Server {
+	if zipCodeCache == nil {
+		zipCodeCache = tz.NewZipCodeCache()
+	}
+
+	s := &Server{
+		rateController: rateController,
+		queueManager:   queueManager,
+		zipCodeCache:   zipCodeCache,
+		mux:            http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /campaigns/{id}/compliance", s.handleGetCompliance)
+	s.mux.HandleFunc("POST /workspaces/{workspace}/campaigns/{campaign}/pause", s.handlePauseCampaign)
+	s.mux.HandleFunc("POST /workspaces/{workspace}/campaigns/{campaign}/resume", s.handleResumeCampaign)
+	s.mux.HandleFunc("POST /workspaces/{workspace}/callbacks", s.handleCreateCallback)
+	s.mux.HandleFunc("DELETE /workspaces/{workspace}/callbacks/{id}", s.handleCancelCallback)
+	s.mux.HandleFunc("POST /workspaces/{workspace}/leads/{lead}/dial-now", s.handleDialNow)
+	s.mux.HandleFunc("GET /workspaces/{workspace}/leads/{lead}/audit", s.handleGetLeadAudit)
+	s.mux.HandleFunc("GET /workspaces/{workspace}/audit", s.handleGetAudit)
+	s.mux.HandleFunc("POST /workspaces/{workspace}/audit", s.handleRecordAudit)
+	s.mux.HandleFunc("GET /workspaces/{workspace}/dry-run", s.handleDryRun)
+	s.mux.HandleFunc("POST /workspaces/{workspace}/calls/{call}/start", s.handleCallStart)
+	s.mux.HandleFunc("POST /workspaces/{workspace}/calls/{call}/heartbeat", s.handleCallHeartbeat)
+	s.mux.HandleFunc("POST /workspaces/{workspace}/calls/{call}/end", s.handleCallEnd)
+	s.mux.HandleFunc("PUT /workspaces/{workspace}/agents", s.handleSetAvailableAgents)
+	s.mux.HandleFunc("GET /workspaces/{workspace}/dead-letters", s.handleGetDeadLetters)
+	s.mux.HandleFunc("POST /workspaces/{workspace}/dead-letters/replay", s.handleReplayDeadLetters)
+	s.mux.HandleFunc("DELETE /workspaces/{workspace}/dead-letters", s.handlePurgeDeadLetters)
+	s.mux.Handle("GET /metrics", metrics.Handler())
+	s.mux.HandleFunc("GET /healthz", s.handleHealthz)
+	s.mux.HandleFunc("GET /readyz", s.handleReadyz)
+
+	return s
+}
+
+// Handler returns the http handler of the server
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// handleGetCompliance returns the abandon rate compliance state of a campaign
+func (s *Server) handleGetCompliance(w http.ResponseWriter, r *http.Request) {
+	campaignID := r.PathValue("id")
+
+	state, err := s.rateController.GetComplianceState(r.Context(), campaignID)
+	if err != nil {
+		logger.Error("failed to get compliance state", logging.CampaignID, campaignID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to get compliance state")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, state)
+}
+
+// dialNowRequest is the body of a dial now request
+type dialNowRequest struct {
+	CampaignID string `json:"campaign_id"`
+	ListNumber string `json:"list_number"`
+}
+
+// handleDialNow pushes a lead ahead of every other lead of its workspace. A
+// lead already queued, with a terminal status or outside its dialing window
+// answers 409.
+func (s *Server) handleDialNow(w http.ResponseWriter, r *http.Request) {
+	workspaceID := r.PathValue("workspace")
+	leadID := r.PathValue("lead")
+
+	var req dialNowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.CampaignID == "" || req.ListNumber == "" {
+		writeError(w, http.StatusBadRequest, "campaign_id and list_number are required")
+		return
+	}
+
+	err := s.queueManager.DialNow(workspaceID, req.CampaignID, req.ListNumber, leadID)
+	switch {
+	case errors.Is(err, db.ErrCampaignNotFound):
+		writeError(w, http.StatusNotFound, "campaign not found")
+		return
+	case errors.Is(err, hopper.ErrListNotInCampaign):
+		writeError(w, http.StatusBadRequest, "list does not belong to the campaign")
+		return
+	case errors.Is(err, hopper.ErrLeadNotDialable), errors.Is(err, hopper.ErrOutsideDialWindow):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if err != nil {
+		logger.Error("failed to dial lead now", logging.WorkspaceID, workspaceID, logging.LeadID, leadID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to queue lead")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// writeJSON writes v as a json response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("failed to write response", "error", err)
+	}
+}
+
+// writeError writes an error message as a json response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,107 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/redis"
+)
+
+// SweepExpiredLeads removes expired leads from every workspace queue and sets
+// them back to dialable so they are picked up in the next valid window
+func (s *Service) SweepExpiredLeads(ctx context.Context) (int, error) {
+	campaigns, err := db.GetAllCampaigns(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("cleanup: failed to get campaigns: %v", err)
+	}
+
+	workspaces := knownWorkspaces(campaigns)
+
+	swept := 0
+	for workspaceID := range workspaces {
+		count, err := s.sweepWorkspace(ctx, workspaceID, time.Now())
+		if err != nil {
+			logger.Error("failed to sweep expired leads", logging.WorkspaceID, workspaceID, "error", err)
+			continue
+		}
+
+		swept += count
+	}
+
+	logger.Info("swept expired leads", "count", swept)
+	return swept, nil
+}
+
+// sweepWorkspace removes the expired leads of a single workspace queue,
+// parked ones included, and records them in the audit history
+func (s *Service) sweepWorkspace(ctx context.Context, workspaceID string, now time.Time) (int, error) {
+	isExpired := func(lead redis.QueuedLead) bool { return lead.IsExpired(now) }
+
+	expired, err := redis.RemoveQueuedLeads(workspaceID, isExpired)
+	if err != nil {
+		return resetQueuedLeads(workspaceID, expired), err
+	}
+
+	parked, err := redis.RemoveParkedLeads(ctx, workspaceID, isExpired)
+	expired = append(expired, parked...)
+	if err != nil {
+		return resetQueuedLeads(workspaceID, expired), err
+	}
+
+	ttl := config.GetQueuedLeadTTL()
+	events := make([]db.AuditEvent, 0, len(expired))
+	for _, lead := range expired {
+		events = append(events, db.AuditEvent{
+			WorkspaceID: workspaceID,
+			At:          now,
+			Type:        db.AuditSkipped,
+			Reason:      expiryReason(lead, ttl),
+			CampaignID:  lead.CampaignID,
+			ListNumber:  lead.ListNumber,
+			LeadID:      lead.LeadID,
+			Detail:      "queued at " + lead.QueuedAt.Format(time.RFC3339),
+		})
+	}
+
+	if err := db.RecordAuditEvents(ctx, events...); err != nil {
+		logger.Error("failed to record expired leads", logging.WorkspaceID, workspaceID, "count", len(events), "error", err)
+	}
+
+	return resetQueuedLeads(workspaceID, expired), nil
+}
+
+// expiryReason tells a lead whose local dialing window closed while queued
+// from one that outlived the queued lead TTL, a lead queued without an expiry
+// only has the TTL
+func expiryReason(lead redis.QueuedLead, ttl time.Duration) string {
+	if !lead.ExpiresAt.IsZero() && lead.ExpiresAt.Before(lead.QueuedAt.Add(ttl)) {
+		return db.AuditReasonOutsideWindow
+	}
+
+	return db.AuditReasonExpired
+}
+
+// resetQueuedLeads sets leads removed from a queue back to dialable, grouped
+// by list, and returns how many were reset
+func resetQueuedLeads(workspaceID string, leads []redis.QueuedLead) int {
+	byList := map[string][]string{}
+	for _, lead := range leads {
+		byList[lead.ListNumber] = append(byList[lead.ListNumber], lead.LeadID)
+	}
+
+	reset := 0
+	for listNumber, leadIDs := range byList {
+		if err := db.BatchUpdateLeadsDialable(workspaceID, listNumber, leadIDs, true); err != nil {
+			logger.Error("failed to reset queued leads", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, "count", len(leadIDs), "error", err)
+			continue
+		}
+
+		reset += len(leadIDs)
+	}
+
+	return reset
+}
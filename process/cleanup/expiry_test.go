@@ -0,0 +1,26 @@
+package cleanup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExpiryReason tests a lead expired by its dialing window is told from
+// one that outlived the queued lead TTL
+func TestExpiryReason(t *testing.T) {
+	queuedAt := time.Date(2025, 3, 10, 21, 0, 0, 0, time.UTC)
+	ttl := 30 * time.Minute
+
+	windowClosed := redis.QueuedLead{QueuedAt: queuedAt, ExpiresAt: queuedAt.Add(10 * time.Minute)}
+	assert.Equal(t, db.AuditReasonOutsideWindow, expiryReason(windowClosed, ttl))
+
+	outlived := redis.QueuedLead{QueuedAt: queuedAt, ExpiresAt: queuedAt.Add(ttl)}
+	assert.Equal(t, db.AuditReasonExpired, expiryReason(outlived, ttl))
+
+	noExpiry := redis.QueuedLead{QueuedAt: queuedAt}
+	assert.Equal(t, db.AuditReasonExpired, expiryReason(noExpiry, ttl))
+}
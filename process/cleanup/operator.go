@@ -0,0 +1,183 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/redis"
+)
+
+// DrainQueue removes every lead queued for a workspace and sets them back to
+// dialable. It returns how many leads were given back to cassandra.
+func (s *Service) DrainQueue(ctx context.Context, workspaceID string) (int, error) {
+	drained, err := redis.RemoveQueuedLeads(workspaceID, func(redis.QueuedLead) bool { return true })
+	reset := resetQueuedLeads(workspaceID, drained)
+	if err != nil {
+		return reset, err
+	}
+
+	if err := redis.UnregisterWorkspace(workspaceID); err != nil {
+		logger.Error("failed to unregister workspace", logging.WorkspaceID, workspaceID, "error", err)
+	}
+
+	logger.Info("drained queue", logging.WorkspaceID, workspaceID, "drained", len(drained), "reset", reset)
+	return reset, nil
+}
+
+// ResetList sets the non-dialable leads of a list back to dialable so they
+// are injected again. Leads still queued or being dialed are left alone.
+func (s *Service) ResetList(ctx context.Context, workspaceID, listNumber string) (int, error) {
+	leadIDs, err := db.GetNonDialableLeadIDs(workspaceID, listNumber)
+	if err != nil {
+		return 0, err
+	}
+
+	queued, err := redis.GetQueuedLeadIDs(workspaceID)
+	if err != nil {
+		return 0, err
+	}
+
+	inFlight, err := redis.GetInFlightLeadIDs(workspaceID, config.GetCallLeaseTTL())
+	if err != nil {
+		return 0, err
+	}
+
+	var reset []string
+	for _, leadID := range leadIDs {
+		if !queued[leadID] && !inFlight[leadID] {
+			reset = append(reset, leadID)
+		}
+	}
+
+	if len(reset) == 0 {
+		return 0, nil
+	}
+
+	if err := db.BatchUpdateLeadsDialable(workspaceID, listNumber, reset, true); err != nil {
+		return 0, err
+	}
+
+	logger.Info("reset list", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, "count", len(reset))
+	return len(reset), nil
+}
+
+// PauseResult describes what pausing or resuming a campaign did to its queued leads
+type PauseResult struct {
+	Parked  int `json:"parked"`
+	Drained int `json:"drained"`
+	Queued  int `json:"queued"`
+	Expired int `json:"expired"`
+}
+
+// PauseCampaign stops a campaign at once. The redis flag goes first so
+// dialers stop getting its leads before the campaign is deactivated in
+// cassandra, which stops the hopper. Its queued leads are parked until it
+// resumes or, with drain, set back to dialable. When cassandra cannot be
+// updated the flag stays, the campaign is not dialed either way.
+func (s *Service) PauseCampaign(ctx context.Context, workspaceID, campaignID string, drain bool) (*PauseResult, error) {
+	parked, err := redis.PauseCampaign(ctx, workspaceID, campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.SetCampaignActive(ctx, workspaceID, campaignID, false); err != nil {
+		return nil, err
+	}
+
+	result := &PauseResult{Parked: parked}
+	if drain {
+		drained, err := s.DrainCampaign(ctx, workspaceID, campaignID)
+		result.Parked, result.Drained = 0, drained
+		if err != nil {
+			return result, err
+		}
+	}
+
+	detail := fmt.Sprintf("%d leads parked", result.Parked)
+	if drain {
+		detail = fmt.Sprintf("%d leads drained", result.Drained)
+	}
+	recordCampaignAudit(ctx, workspaceID, campaignID, db.AuditPaused, detail)
+
+	return result, nil
+}
+
+// ResumeCampaign activates a paused campaign in cassandra and queues its
+// parked leads again. The parked leads that expired while it was paused are
+// set back to dialable instead.
+func (s *Service) ResumeCampaign(ctx context.Context, workspaceID, campaignID string) (*PauseResult, error) {
+	now := time.Now()
+	expired, err := redis.RemoveParkedLeads(ctx, workspaceID, func(lead redis.QueuedLead) bool {
+		return lead.CampaignID == campaignID && lead.IsExpired(now)
+	})
+	reset := resetQueuedLeads(workspaceID, expired)
+	if err != nil {
+		return &PauseResult{Expired: reset}, err
+	}
+
+	if err := db.SetCampaignActive(ctx, workspaceID, campaignID, true); err != nil {
+		return nil, err
+	}
+
+	queued, err := redis.ResumeCampaign(ctx, workspaceID, campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	recordCampaignAudit(ctx, workspaceID, campaignID, db.AuditResumed, fmt.Sprintf("%d leads queued, %d expired", queued, reset))
+	return &PauseResult{Queued: queued, Expired: reset}, nil
+}
+
+// DrainCampaign removes the queued and parked leads of a campaign and sets
+// them back to dialable. It returns how many leads were given back to cassandra.
+func (s *Service) DrainCampaign(ctx context.Context, workspaceID, campaignID string) (int, error) {
+	drained, err := redis.RemoveQueuedLeads(workspaceID, func(lead redis.QueuedLead) bool {
+		return lead.CampaignID == campaignID
+	})
+	if err != nil {
+		return resetQueuedLeads(workspaceID, drained), err
+	}
+
+	parked, err := redis.RemoveParkedLeads(ctx, workspaceID, func(lead redis.QueuedLead) bool {
+		return lead.CampaignID == campaignID
+	})
+	drained = append(drained, parked...)
+	reset := resetQueuedLeads(workspaceID, drained)
+	if err != nil {
+		return reset, err
+	}
+
+	logger.Info("drained campaign", logging.WorkspaceID, workspaceID, logging.CampaignID, campaignID, "drained", len(drained), "reset", reset)
+	return reset, nil
+}
+
+// recordCampaignAudit records a campaign wide event, a failure is only logged
+func recordCampaignAudit(ctx context.Context, workspaceID, campaignID, eventType, detail string) {
+	err := db.RecordAuditEvents(ctx, db.AuditEvent{
+		WorkspaceID: workspaceID,
+		Type:        eventType,
+		CampaignID:  campaignID,
+		Detail:      detail,
+	})
+	if err != nil {
+		logger.Error("failed to record audit event", logging.WorkspaceID, workspaceID, logging.CampaignID, campaignID, "type", eventType, "error", err)
+	}
+}
+
+// PurgeDeadLetters deletes the dead letter queue of a workspace and sets its
+// leads back to dialable. It returns how many entries were purged and how
+// many leads were given back to cassandra.
+func (s *Service) PurgeDeadLetters(ctx context.Context, workspaceID string) (int, int, error) {
+	leads, purged, err := redis.PurgeDeadLetters(workspaceID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	reset := resetQueuedLeads(workspaceID, leads)
+	logger.Info("purged dead letters", logging.WorkspaceID, workspaceID, "purged", purged, "reset", reset)
+	return purged, reset, nil
+}
@@ -0,0 +1,91 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/redis"
+)
+
+// reconcileGracePeriod leaves leads alone that were just taken by a running
+// injection and may not be queued yet
+const reconcileGracePeriod = 5 * time.Minute
+
+// Reconcile restores to dialable the leads cassandra marks as taken that are
+// neither queued nor in flight in redis, e.g. after redis was flushed or
+// restarted. It returns the number of leads restored.
+func (s *Service) Reconcile(ctx context.Context) (int, error) {
+	campaigns, err := db.GetAllCampaigns(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("cleanup: failed to get campaigns: %v", err)
+	}
+
+	workspaces := knownWorkspaces(campaigns)
+
+	restored := 0
+	for workspaceID := range workspaces {
+		count, err := s.reconcileWorkspace(workspaceID)
+		if err != nil {
+			logger.Error("failed to reconcile workspace", logging.WorkspaceID, workspaceID, "error", err)
+			continue
+		}
+
+		restored += count
+	}
+
+	logger.Info("reconciliation done", "restored", restored)
+	return restored, nil
+}
+
+// reconcileWorkspace restores the orphaned leads of a single workspace
+func (s *Service) reconcileWorkspace(workspaceID string) (int, error) {
+	queued, err := redis.GetQueuedLeadIDs(workspaceID)
+	if err != nil {
+		return 0, err
+	}
+
+	inFlight, err := redis.GetInFlightLeadIDs(workspaceID, config.GetCallLeaseTTL())
+	if err != nil {
+		return 0, err
+	}
+
+	lists, err := db.GetListsByWorkspace(workspaceID)
+	if err != nil {
+		return 0, err
+	}
+
+	takenBefore := time.Now().Add(-reconcileGracePeriod)
+	restored := 0
+	for _, list := range lists {
+		leadIDs, err := db.GetUndispositionedLeadIDs(workspaceID, list.ListNumber, takenBefore)
+		if err != nil {
+			logger.Error("failed to get undispositioned leads", logging.WorkspaceID, workspaceID, logging.ListNumber, list.ListNumber, "error", err)
+			continue
+		}
+
+		var orphans []string
+		for _, leadID := range leadIDs {
+			if !queued[leadID] && !inFlight[leadID] {
+				orphans = append(orphans, leadID)
+			}
+		}
+
+		if len(orphans) == 0 {
+			continue
+		}
+
+		if err := db.BatchUpdateLeadsDialable(workspaceID, list.ListNumber, orphans, true); err != nil {
+			logger.Error("failed to restore orphaned leads", logging.WorkspaceID, workspaceID, logging.ListNumber, list.ListNumber, "count", len(orphans), "error", err)
+			continue
+		}
+
+		logger.Info("restored orphaned leads", logging.WorkspaceID, workspaceID, logging.ListNumber, list.ListNumber, "count", len(orphans))
+		restored += len(orphans)
+	}
+
+	return restored, nil
+}
@@ -0,0 +1,247 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/metrics"
+	"github.com/nico-phil/process/redis"
+)
+
+var logger = logging.For("cleanup")
+
+// Service reclaims leads of campaigns and lists that have been deactivated
+type Service struct {
+}
+
+// NewService creates a new cleanup service
+func NewService() *Service {
+	return &Service{}
+}
+
+// Report describes what a cleanup run reclaimed
+type Report struct {
+	EvictedLeads       int
+	ResetLeads         int
+	DeactivatedLists   []string
+	TrimmedDeadLetters int
+}
+
+// Run evicts queued leads whose campaign or list is no longer active, trims
+// full dead letter queues and resets the undispositioned leads of lists
+// deactivated since the last run. Paused campaigns count as active. The first
+// run has nothing to compare with, it only stores the active lists. The
+// active lists are only stored once every reset succeeded, a failed list is
+// retried by the next run.
+func (s *Service) Run(ctx context.Context) (*Report, error) {
+	campaigns, err := db.GetAllCampaigns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cleanup: failed to get campaigns: %v", err)
+	}
+
+	lists, err := db.GetAllLists()
+	if err != nil {
+		return nil, fmt.Errorf("cleanup: failed to get lists: %v", err)
+	}
+
+	workspaces := knownWorkspaces(campaigns)
+
+	// a paused campaign is inactive in cassandra but keeps its leads, parked
+	// until it resumes, it is not reclaimed like a deactivated one
+	activeCampaigns := map[string]bool{}
+	for _, campaign := range campaigns {
+		activeCampaigns[campaign.ID] = campaign.Active
+	}
+	for workspaceID := range workspaces {
+		paused, err := redis.GetPausedCampaigns(ctx, workspaceID)
+		if err != nil {
+			return nil, fmt.Errorf("cleanup: failed to get paused campaigns: %v", err)
+		}
+
+		for campaignID := range paused {
+			activeCampaigns[campaignID] = true
+		}
+	}
+
+	now := time.Now()
+	activeLists := map[string]bool{}
+	var activeListKeys []string
+	for _, list := range lists {
+		key := listKey(list.WorkspaceID, list.ListNumber)
+		if list.IsActiveAt(now) && activeCampaigns[list.CampaignID] {
+			activeLists[key] = true
+			activeListKeys = append(activeListKeys, key)
+		}
+	}
+
+	report := &Report{}
+
+	// evict queued leads that would otherwise still be dialed
+	for workspaceID := range workspaces {
+		evicted, err := redis.RemoveQueuedLeads(workspaceID, func(lead redis.QueuedLead) bool {
+			return !activeCampaigns[lead.CampaignID] || !activeLists[listKey(lead.WorkspaceID, lead.ListNumber)]
+		})
+		if err != nil {
+			logger.Error("failed to evict leads", logging.WorkspaceID, workspaceID, "error", err)
+		}
+
+		if len(evicted) > 0 {
+			logger.Info("evicted leads of inactive campaigns or lists", logging.WorkspaceID, workspaceID, "count", len(evicted))
+		}
+		report.EvictedLeads += len(evicted)
+		report.TrimmedDeadLetters += trimDeadLetters(workspaceID)
+
+		if err := redis.UnregisterWorkspace(workspaceID); err != nil {
+			logger.Error("failed to unregister workspace", logging.WorkspaceID, workspaceID, "error", err)
+		}
+	}
+
+	// lists active last run and not anymore have just been deactivated
+	previous, stored, err := redis.GetActiveLists()
+	if err != nil {
+		return report, fmt.Errorf("cleanup: failed to detect deactivated lists: %v", err)
+	}
+
+	if !stored {
+		if err := redis.StoreActiveLists(activeListKeys); err != nil {
+			return report, fmt.Errorf("cleanup: failed to store active lists: %v", err)
+		}
+
+		logger.Info("stored active lists of first cleanup run", "evicted", report.EvictedLeads, "active_lists", len(activeListKeys))
+		return report, nil
+	}
+
+	failed := 0
+	for _, key := range previous {
+		if activeLists[key] {
+			continue
+		}
+
+		workspaceID, listNumber, ok := parseListKey(key)
+		if !ok {
+			continue
+		}
+
+		reset, err := s.resetList(workspaceID, listNumber)
+		if err != nil {
+			logger.Error("failed to reset list", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, "error", err)
+			failed++
+			continue
+		}
+
+		report.DeactivatedLists = append(report.DeactivatedLists, key)
+		report.ResetLeads += reset
+	}
+
+	if failed > 0 {
+		return report, fmt.Errorf("cleanup: failed to reset %d deactivated lists", failed)
+	}
+
+	if err := redis.StoreActiveLists(activeListKeys); err != nil {
+		return report, fmt.Errorf("cleanup: failed to store active lists: %v", err)
+	}
+
+	logger.Info("cleanup done", "evicted", report.EvictedLeads, "reset", report.ResetLeads, "deactivated_lists", len(report.DeactivatedLists),
+		"trimmed_dead_letters", report.TrimmedDeadLetters)
+
+	return report, nil
+}
+
+// resetList sets the undispositioned leads of a list back to dialable, the
+// leads taken for a call that never happened. Dispositioned leads stay non
+// dialable, and so do the leads still queued, i.e. parked while their
+// campaign is paused, and the leads being dialed.
+func (s *Service) resetList(workspaceID, listNumber string) (int, error) {
+	undispositioned, err := db.GetUndispositionedLeadIDs(workspaceID, listNumber, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	queued, err := redis.GetQueuedLeadIDs(workspaceID)
+	if err != nil {
+		return 0, err
+	}
+
+	inFlight, err := redis.GetInFlightLeadIDs(workspaceID, config.GetCallLeaseTTL())
+	if err != nil {
+		return 0, err
+	}
+
+	var leadIDs []string
+	for _, leadID := range undispositioned {
+		if !queued[leadID] && !inFlight[leadID] {
+			leadIDs = append(leadIDs, leadID)
+		}
+	}
+
+	if len(leadIDs) == 0 {
+		return 0, nil
+	}
+
+	if err := db.BatchUpdateLeadsDialable(workspaceID, listNumber, leadIDs, true); err != nil {
+		return 0, err
+	}
+
+	logger.Info("reset leads of deactivated list", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, "count", len(leadIDs))
+	return len(leadIDs), nil
+}
+
+// trimDeadLetters drops the oldest dead letters of a full dead letter queue
+// and marks their leads terminal, otherwise reconciliation would restore
+// leads that failed too often. It returns the number of entries dropped.
+func trimDeadLetters(workspaceID string) int {
+	leads, trimmed, err := redis.TrimDeadLetters(workspaceID)
+	if err != nil {
+		logger.Error("failed to trim dead letters", logging.WorkspaceID, workspaceID, "error", err)
+		return 0
+	}
+
+	if trimmed == 0 {
+		return 0
+	}
+
+	metrics.DeadLettersTrimmed.WithLabelValues(workspaceID).Add(float64(trimmed))
+	logger.Warn("dropped oldest dead letters of a full dead letter queue", logging.WorkspaceID, workspaceID, "count", trimmed, "leads", len(leads))
+
+	for _, lead := range leads {
+		if err := db.UpdateLeadStatus(workspaceID, lead.ListNumber, lead.LeadID, db.LeadStatusDeadLetterDropped); err != nil {
+			logger.Error("failed to mark dropped dead letter lead", logging.WorkspaceID, workspaceID, logging.ListNumber, lead.ListNumber, logging.LeadID, lead.LeadID, "error", err)
+		}
+	}
+
+	return trimmed
+}
+
+// knownWorkspaces returns the workspaces of the campaigns and the workspaces
+// registered in redis, which may still hold leads of deleted campaigns
+func knownWorkspaces(campaigns []db.Campaign) map[string]bool {
+	workspaces := map[string]bool{}
+	for _, campaign := range campaigns {
+		workspaces[campaign.WorkspaceID] = true
+	}
+
+	registered, err := redis.GetActiveWorkspaces()
+	if err != nil {
+		logger.Error("failed to get registered workspaces", "error", err)
+	}
+	for _, workspaceID := range registered {
+		workspaces[workspaceID] = true
+	}
+
+	return workspaces
+}
+
+// listKey identifies a list across workspaces
+func listKey(workspaceID, listNumber string) string {
+	return workspaceID + ":" + listNumber
+}
+
+// parseListKey splits a key built by listKey
+func parseListKey(key string) (string, string, bool) {
+	return strings.Cut(key, ":")
+}
@@ -0,0 +1,24 @@
+package cleanup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseListKey tests list keys round trip through parseListKey
+func TestParseListKey(t *testing.T) {
+	workspaceID, listNumber, ok := parseListKey(listKey("ws1", "l1"))
+	assert.True(t, ok)
+	assert.Equal(t, "ws1", workspaceID)
+	assert.Equal(t, "l1", listNumber)
+
+	// list numbers may hold the separator, workspace ids do not
+	workspaceID, listNumber, ok = parseListKey(listKey("ws1", "l:1"))
+	assert.True(t, ok)
+	assert.Equal(t, "ws1", workspaceID)
+	assert.Equal(t, "l:1", listNumber)
+
+	_, _, ok = parseListKey("ws1")
+	assert.False(t, ok)
+}
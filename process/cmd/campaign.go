@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nico-phil/process/cleanup"
+)
+
+// runCampaign runs a campaign subcommand
+func runCampaign(ctx context.Context, args []string) error {
+	subcommands := map[string]func(context.Context, []string) error{
+		"pause":  runCampaignPause,
+		"resume": runCampaignResume,
+	}
+
+	if len(args) == 0 || subcommands[args[0]] == nil {
+		fmt.Fprintln(os.Stderr, "usage: process campaign pause|resume --workspace ID --campaign ID")
+		return errUsage
+	}
+
+	return subcommands[args[0]](ctx, args[1:])
+}
+
+// runCampaignPause pauses a campaign, parking or draining its queued leads
+func runCampaignPause(ctx context.Context, args []string) error {
+	flags := newFlagSet("campaign pause", "campaign pause --workspace ID --campaign ID [--drain]")
+	workspaceID := flags.String("workspace", "", "workspace of the campaign")
+	campaignID := flags.String("campaign", "", "campaign to pause")
+	drain := flags.Bool("drain", false, "set the queued leads back to dialable instead of parking them")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if err := requireFlag(flags, "workspace", *workspaceID); err != nil {
+		return err
+	}
+	if err := requireFlag(flags, "campaign", *campaignID); err != nil {
+		return err
+	}
+
+	if err := connect(ctx, true, true); err != nil {
+		return err
+	}
+
+	result, err := cleanup.NewService().PauseCampaign(ctx, *workspaceID, *campaignID, *drain)
+	if err != nil {
+		return err
+	}
+
+	if *drain {
+		fmt.Printf("campaign %s paused: %d leads set back to dialable\n", *campaignID, result.Drained)
+		return nil
+	}
+
+	fmt.Printf("campaign %s paused: %d leads parked\n", *campaignID, result.Parked)
+	return nil
+}
+
+// runCampaignResume resumes a paused campaign and queues its parked leads again
+func runCampaignResume(ctx context.Context, args []string) error {
+	flags := newFlagSet("campaign resume", "campaign resume --workspace ID --campaign ID")
+	workspaceID := flags.String("workspace", "", "workspace of the campaign")
+	campaignID := flags.String("campaign", "", "campaign to resume")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if err := requireFlag(flags, "workspace", *workspaceID); err != nil {
+		return err
+	}
+	if err := requireFlag(flags, "campaign", *campaignID); err != nil {
+		return err
+	}
+
+	if err := connect(ctx, true, true); err != nil {
+		return err
+	}
+
+	result, err := cleanup.NewService().ResumeCampaign(ctx, *workspaceID, *campaignID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("campaign %s resumed: %d parked leads queued again, %d expired and set back to dialable\n", *campaignID, result.Queued, result.Expired)
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/nico-phil/process/hopper"
+	"github.com/nico-phil/process/ratelimit"
+	"github.com/nico-phil/process/redis"
+)
+
+// runCycle injects leads once, for every workspace or the one given. With
+// --dry-run it prints what the cycle would inject instead.
+func runCycle(ctx context.Context, args []string) error {
+	flags := newFlagSet("cycle", "cycle [--workspace ID] [--dry-run]")
+	workspaceID := flags.String("workspace", "", "only inject the leads of this workspace")
+	dryRun := flags.Bool("dry-run", false, "print what would be injected and skipped without writing anything")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *dryRun {
+		if err := requireFlag(flags, "workspace", *workspaceID); err != nil {
+			return err
+		}
+	}
+
+	if err := connect(ctx, true, true); err != nil {
+		return err
+	}
+
+	queueManager := hopper.NewQueueManager(ratelimit.NewRateController(), loadZipCodes())
+	if *dryRun {
+		report, err := queueManager.DryRunWorkspace(ctx, *workspaceID)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	if *workspaceID == "" {
+		if err := queueManager.ProcessAllWorkspacesWithContext(ctx); err != nil {
+			return err
+		}
+
+		cycle := hopper.LastCycle()
+		fmt.Printf("processed %d of %d workspaces in %.1fs\n", cycle.Processed, cycle.Workspaces, cycle.DurationSeconds)
+		return nil
+	}
+
+	if err := queueManager.ProcessWorkspaceByID(ctx, *workspaceID); err != nil {
+		return err
+	}
+
+	queued, err := redis.GetQueueLength(ctx, *workspaceID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("workspace %s: %d leads queued\n", *workspaceID, queued)
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/nico-phil/process/config"
+)
+
+// runHealthcheck checks the api server of a process running next to it
+// answers /healthz, for container health checks. It reads the address the
+// server listens on from the same configuration.
+func runHealthcheck(ctx context.Context, args []string) error {
+	flags := newFlagSet("healthcheck", "healthcheck [--timeout 3s]")
+	timeout := flags.Duration("timeout", 3*time.Second, "how long to wait for the answer")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	url, err := healthURL(config.GetHTTPAddr())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("health check failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check failed: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// healthURL returns the /healthz url of a server listening on addr, reached
+// on the loopback when it listens on every interface
+func healthURL(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid http address %q: %v", addr, err)
+	}
+
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+
+	return "http://" + net.JoinHostPort(host, port) + "/healthz", nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHealthURL tests the health url follows the configured listen address
+func TestHealthURL(t *testing.T) {
+	cases := map[string]string{
+		":8080":          "http://localhost:8080/healthz",
+		"0.0.0.0:9090":   "http://localhost:9090/healthz",
+		"[::]:9090":      "http://localhost:9090/healthz",
+		"127.0.0.1:8081": "http://127.0.0.1:8081/healthz",
+	}
+
+	for addr, expected := range cases {
+		url, err := healthURL(addr)
+		assert.NoError(t, err, addr)
+		assert.Equal(t, expected, url, addr)
+	}
+
+	_, err := healthURL("8080")
+	assert.Error(t, err)
+}
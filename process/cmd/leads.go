@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nico-phil/process/cleanup"
+	"github.com/nico-phil/process/db"
+)
+
+// runLeads runs a leads subcommand
+func runLeads(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "reset" {
+		fmt.Fprintln(os.Stderr, "usage: process leads reset --list N [--workspace ID]")
+		return errUsage
+	}
+
+	return runLeadsReset(ctx, args[1:])
+}
+
+// runLeadsReset sets the non-dialable leads of a list back to dialable
+func runLeadsReset(ctx context.Context, args []string) error {
+	flags := newFlagSet("leads reset", "leads reset --list N [--workspace ID]")
+	listNumber := flags.String("list", "", "list whose leads are reset")
+	workspaceID := flags.String("workspace", "", "workspace of the list, found from the list when omitted")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if err := requireFlag(flags, "list", *listNumber); err != nil {
+		return err
+	}
+
+	if err := connect(ctx, true, true); err != nil {
+		return err
+	}
+
+	if *workspaceID == "" {
+		found, err := listWorkspace(*listNumber)
+		if err != nil {
+			return err
+		}
+		*workspaceID = found
+	}
+
+	reset, err := cleanup.NewService().ResetList(ctx, *workspaceID, *listNumber)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("list %s of workspace %s: %d leads set back to dialable\n", *listNumber, *workspaceID, reset)
+	return nil
+}
+
+// listWorkspace returns the workspace a list number belongs to
+func listWorkspace(listNumber string) (string, error) {
+	lists, err := db.GetAllLists()
+	if err != nil {
+		return "", err
+	}
+
+	workspaces := map[string]bool{}
+	for _, list := range lists {
+		if list.ListNumber == listNumber {
+			workspaces[list.WorkspaceID] = true
+		}
+	}
+
+	switch len(workspaces) {
+	case 0:
+		return "", fmt.Errorf("list %s not found", listNumber)
+	case 1:
+		for workspaceID := range workspaces {
+			return workspaceID, nil
+		}
+	}
+
+	return "", fmt.Errorf("list %s exists in %d workspaces, pass --workspace", listNumber, len(workspaces))
+}
@@ -1,35 +1,176 @@
 package main
 
 import (
-	"github.com/nico-phil/process/db"
-	"github.com/nico-phil/process/redis"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/tracing"
 )
 
+// configWatchInterval is how often the config file is checked for changes
+const configWatchInterval = 10 * time.Second
+
+// tracingShutdownTimeout bounds how long buffered spans are flushed on exit
+const tracingShutdownTimeout = 5 * time.Second
+
+// errUsage is returned by a command called with invalid arguments, once its
+// usage has been printed
+var errUsage = errors.New("invalid usage")
+
+// command is a subcommand of the process binary
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// commands lists every subcommand, run is used when none is given
+var commands = []command{
+	{"run", "run the hopper, reconciler and api server", runProcess},
+	{"cycle", "run a single injection cycle, for every workspace or one", runCycle},
+	{"queue", "inspect, drain or purge the queue of a workspace", runQueue},
+	{"campaign", "pause or resume a campaign", runCampaign},
+	{"leads", "reset the non-dialable leads of a list", runLeads},
+	{"tz", "download the zip code time zone data again", runTZ},
+	{"migrate", "apply the cassandra schema migrations", runMigrate},
+	{"healthcheck", "check the api server of a running process is healthy", runHealthcheck},
+}
+
 func main() {
+	args := os.Args[1:]
 
-	err := db.NewClient()
-	if err != nil {
+	// without a command the process runs, as it always has
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	} else if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		printUsage(os.Stdout)
 		return
 	}
 
-	err = redis.InitRedis()
-	if err != nil {
+	if name == "help" {
+		printUsage(os.Stdout)
 		return
 	}
 
-	// orchestrator := orchestrator.New()
-	// orchestrator.Start()
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
 
-	// , _ := db.GetAllCampaigns()
+	if err := execute(cmd, args); err != nil {
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
 
-	// result, _ := redis.IncrementCallCount("workspace-1")
-	// fmt.Println(result, result)
+		slog.Error("process stopped", "command", cmd.name, "error", err)
+		os.Exit(1)
+	}
+}
+
+// execute loads the configuration, sets up logging and tracing, then runs
+// cmd until it returns or the process is interrupted
+func execute(cmd command, args []string) error {
+	if err := config.Load(os.Getenv("CONFIG_FILE")); err != nil {
+		return fmt.Errorf("invalid configuration:\n%v", err)
+	}
+
+	if err := logging.Setup(os.Stderr, config.GetLogLevel(), config.GetLogFormat()); err != nil {
+		return fmt.Errorf("invalid log configuration: %v", err)
+	}
 
-	// const geoNamesZipURL = "http://download.geonames.org/export/zip/US.zip"
+	if config.GetTracingEnabled() {
+		shutdown, err := tracing.Setup(context.Background(), config.GetOTLPEndpoint(), config.GetOTLPInsecure(), config.GetTracingServiceName(), config.GetTracingSampleRatio())
+		if err != nil {
+			return fmt.Errorf("failed to set up tracing: %v", err)
+		}
 
-	// const dataDir = "/data"
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				slog.Error("failed to flush spans", "error", err)
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return cmd.run(ctx, args)
+}
 
-	// const zipFilePath = dataDir + "/US.zip"
+// findCommand returns the command called name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+
+	return command{}, false
+}
+
+// printUsage lists the commands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: process [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run process <command> -h for the flags of a command. The configuration")
+	fmt.Fprintln(w, "is read from $CONFIG_FILE, YAML or TOML by its extension, and the environment.")
+}
+
+// newFlagSet creates the flags of a command, usage is its synopsis
+func newFlagSet(name, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: process %s\n", usage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// parseFlags parses args, a help request is reported as errUsage too so the
+// command does not run
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if flags.NArg() > 0 {
+		fmt.Fprintf(flags.Output(), "unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		flags.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+// requireFlag reports a missing required flag
+func requireFlag(flags *flag.FlagSet, name, value string) error {
+	if value != "" {
+		return nil
+	}
 
-	// fmt.Println(tz.DownLoadZipData(context.TODO(), http.Client{}, geoNamesZipURL, zipFilePath))
+	fmt.Fprintf(flags.Output(), "--%s is required\n", name)
+	flags.Usage()
+	return errUsage
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nico-phil/process/db"
+)
+
+// runMigrate applies the cassandra schema migrations not applied yet
+func runMigrate(ctx context.Context, args []string) error {
+	flags := newFlagSet("migrate", "migrate")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if err := connect(ctx, true, false); err != nil {
+		return err
+	}
+
+	applied, err := db.Migrate(ctx)
+	for _, migration := range applied {
+		fmt.Printf("applied %04d %s\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("schema is up to date")
+	}
+
+	return nil
+}
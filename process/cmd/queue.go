@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nico-phil/process/cleanup"
+	"github.com/nico-phil/process/redis"
+)
+
+// runQueue runs a queue subcommand on the queue of a workspace
+func runQueue(ctx context.Context, args []string) error {
+	subcommands := map[string]func(context.Context, []string) error{
+		"inspect": runQueueInspect,
+		"drain":   runQueueDrain,
+		"purge":   runQueuePurge,
+	}
+
+	if len(args) == 0 || subcommands[args[0]] == nil {
+		fmt.Fprintln(os.Stderr, "usage: process queue inspect|drain|purge --workspace ID")
+		return errUsage
+	}
+
+	return subcommands[args[0]](ctx, args[1:])
+}
+
+// runQueueInspect prints the lanes, calls in progress and dead letters of a
+// workspace, and the leads that will be dequeued next
+func runQueueInspect(ctx context.Context, args []string) error {
+	flags := newFlagSet("queue inspect", "queue inspect --workspace ID [--leads N]")
+	workspaceID := flags.String("workspace", "", "workspace of the queue")
+	leads := flags.Int("leads", 5, "number of leads to show per lane, in dequeue order")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if err := requireFlag(flags, "workspace", *workspaceID); err != nil {
+		return err
+	}
+
+	if err := connect(ctx, false, true); err != nil {
+		return err
+	}
+
+	lanes, err := redis.GetLaneLengths(*workspaceID)
+	if err != nil {
+		return err
+	}
+
+	calls, err := redis.GetCallCount(ctx, *workspaceID)
+	if err != nil {
+		return err
+	}
+
+	deadLetters, err := redis.GetDeadLetterCount(*workspaceID)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "workspace\t%s\n", *workspaceID)
+	for _, lane := range lanes {
+		fmt.Fprintf(w, "%s lane\t%d\n", lane.Lane, lane.Length)
+	}
+	fmt.Fprintf(w, "calls in progress\t%d\n", calls)
+	fmt.Fprintf(w, "dead letters\t%d\n", deadLetters)
+
+	for _, lane := range lanes {
+		if lane.Length == 0 || *leads <= 0 {
+			continue
+		}
+
+		next, err := redis.PeekQueuedLeads(*workspaceID, lane.Lane, *leads)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "\nnext in %s lane\n", lane.Lane)
+		fmt.Fprintln(w, "LEAD\tLIST\tCAMPAIGN\tQUEUED\tEXPIRES")
+		for _, lead := range next {
+			expires := "-"
+			if !lead.ExpiresAt.IsZero() {
+				expires = lead.ExpiresAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", lead.LeadID, lead.ListNumber, lead.CampaignID, lead.QueuedAt.Format(time.RFC3339), expires)
+		}
+	}
+
+	return w.Flush()
+}
+
+// runQueueDrain empties the queue of a workspace and gives its leads back to cassandra
+func runQueueDrain(ctx context.Context, args []string) error {
+	flags := newFlagSet("queue drain", "queue drain --workspace ID")
+	workspaceID := flags.String("workspace", "", "workspace of the queue")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if err := requireFlag(flags, "workspace", *workspaceID); err != nil {
+		return err
+	}
+
+	if err := connect(ctx, true, true); err != nil {
+		return err
+	}
+
+	reset, err := cleanup.NewService().DrainQueue(ctx, *workspaceID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("workspace %s: %d leads set back to dialable\n", *workspaceID, reset)
+	return nil
+}
+
+// runQueuePurge deletes the queue of a workspace without touching cassandra
+func runQueuePurge(ctx context.Context, args []string) error {
+	flags := newFlagSet("queue purge", "queue purge --workspace ID --yes")
+	workspaceID := flags.String("workspace", "", "workspace of the queue")
+	yes := flags.Bool("yes", false, "confirm the queued leads are dropped, reconciliation restores them later")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if err := requireFlag(flags, "workspace", *workspaceID); err != nil {
+		return err
+	}
+	if !*yes {
+		fmt.Fprintln(flags.Output(), "purge drops every queued lead, use queue drain to give them back to cassandra or pass --yes")
+		return errUsage
+	}
+
+	if err := connect(ctx, false, true); err != nil {
+		return err
+	}
+
+	purged, err := redis.PurgeQueue(*workspaceID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("workspace %s: %d queue entries dropped\n", *workspaceID, purged)
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/redis"
+)
+
+// backoff describes how a dependency is retried at startup
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+	maxWait time.Duration
+}
+
+// connectWithRetry calls connect until it succeeds. The wait doubles after
+// every failure up to b.max, the last attempt is made when b.maxWait is over.
+func connectWithRetry(ctx context.Context, name string, b backoff, connect func() error) error {
+	deadline := time.Now().Add(b.maxWait)
+	wait := b.initial
+
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("failed to connect to %s after %d attempts: %v", name, attempt, err)
+		}
+		wait = min(wait, remaining)
+
+		slog.Warn("dependency unavailable, retrying", "dependency", name, "attempt", attempt, "retry_in", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		wait = min(wait*2, b.max)
+	}
+}
+
+// connect connects to cassandra and redis, as needed by a command, retrying
+// each with the configured startup backoff
+func connect(ctx context.Context, withCassandra, withRedis bool) error {
+	startup := backoff{
+		initial: config.GetStartupInitialBackoff(),
+		max:     config.GetStartupMaxBackoff(),
+		maxWait: config.GetStartupMaxWait(),
+	}
+
+	if withCassandra {
+		if err := connectWithRetry(ctx, "cassandra", startup, db.NewClient); err != nil {
+			return err
+		}
+	}
+
+	if withRedis {
+		if err := connectWithRetry(ctx, "redis", startup, redis.InitRedis); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
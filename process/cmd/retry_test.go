@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConnectWithRetry tests a dependency is retried until it comes up
+func TestConnectWithRetry(t *testing.T) {
+	attempts := 0
+	err := connectWithRetry(context.Background(), "cassandra", backoff{initial: time.Millisecond, max: 2 * time.Millisecond, maxWait: time.Second}, func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+// TestConnectWithRetryGivesUp tests retries stop after the max wait
+func TestConnectWithRetryGivesUp(t *testing.T) {
+	attempts := 0
+	err := connectWithRetry(context.Background(), "redis", backoff{initial: 10 * time.Millisecond, max: 20 * time.Millisecond, maxWait: 50 * time.Millisecond}, func() error {
+		attempts++
+		return errors.New("connection refused")
+	})
+
+	assert.ErrorContains(t, err, "failed to connect to redis")
+	assert.Less(t, attempts, 6)
+	assert.Greater(t, attempts, 1)
+}
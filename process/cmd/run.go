@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/nico-phil/process/api"
+	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/hopper"
+	"github.com/nico-phil/process/orchestrator"
+	"github.com/nico-phil/process/ratelimit"
+	"github.com/nico-phil/process/redis"
+	"github.com/nico-phil/process/tz"
+)
+
+// runProcess serves the api and runs the hopper and the reconciler until ctx
+// is done or the api server stops
+func runProcess(ctx context.Context, args []string) error {
+	flags := newFlagSet("run", "run")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if configPath := os.Getenv("CONFIG_FILE"); configPath != "" {
+		go config.Watch(ctx, configPath, configWatchInterval)
+	}
+
+	zipCodeCache := loadZipCodes()
+
+	// serve health checks while cassandra and redis come up, /readyz answers
+	// 503 until both are connected
+	rateController := ratelimit.NewRateController()
+	server := api.NewServer(rateController, hopper.NewQueueManager(rateController, zipCodeCache), zipCodeCache)
+	addr := config.GetHTTPAddr()
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("api listening", "addr", addr)
+		serverErr <- http.ListenAndServe(addr, server.Handler())
+	}()
+
+	if err := connect(ctx, true, true); err != nil {
+		return err
+	}
+
+	// move redis data written by earlier releases before the hopper runs
+	if migrated, err := redis.MigrateKeys(ctx); err != nil {
+		slog.Error("failed to migrate redis keys", "error", err)
+	} else if migrated > 0 {
+		slog.Info("migrated redis keys", "count", migrated)
+	}
+
+	// leads popped by a dialer go to the audit history
+	redis.OnDequeue(hopper.RecordDequeued)
+
+	// replace the cassandra session if it stops answering
+	go db.KeepAlive(ctx, config.GetCassandraReconnectInterval())
+
+	// restore leads stranded by a redis flush at startup and periodically
+	po := orchestrator.New(zipCodeCache)
+	go po.StartReconciler(ctx, config.GetReconcileInterval())
+
+	// inject leads every tick
+	go po.Run(ctx)
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("api server stopped: %v", err)
+	case <-ctx.Done():
+		slog.Info("process interrupted, stopping")
+		return nil
+	}
+}
+
+// loadZipCodes loads the zip code time zones, leads get no local time when
+// they are unavailable
+func loadZipCodes() *tz.ZipCodeCache {
+	zipCodeCache, err := tz.LoadZipCodeData()
+	if err != nil {
+		slog.Warn("failed to load zip code data, lead time zones are unavailable", "error", err)
+		return nil
+	}
+
+	return zipCodeCache
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/tz"
+)
+
+// runTZ runs a tz subcommand
+func runTZ(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "refresh" {
+		fmt.Fprintln(os.Stderr, "usage: process tz refresh")
+		return errUsage
+	}
+
+	flags := newFlagSet("tz refresh", "tz refresh")
+	if err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+
+	cache, err := tz.RefreshZipCodeData(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("loaded %d zip codes into %s\n", cache.Len(), config.GetZipCodeDataDir())
+	return nil
+}
@@ -1,38 +1,253 @@
 package config
 
 import (
-	"os"
 	"strings"
+	"time"
 )
 
 func GetContactPoints() []string {
-	valueStr := os.Getenv("CASSANDRA_CONTACT_POINTS")
-	if valueStr == "" {
-		return []string{"cassandra-node1:9042"}
-	}
-
-	valueArray := strings.Split(valueStr, " ")
-	return valueArray
+	return current().Cassandra.ContactPoints
 }
 
 func GetKeyspace() string {
-	keypsace := os.Getenv("CASSANDRA_KEYSPACE")
-	if keypsace == "" {
-		return "pluralistmanagement"
-	}
-
-	return keypsace
+	return current().Cassandra.Keyspace
 }
 
 func GetRedisArr() string {
-	addr := os.Getenv("REDIS_ADDR")
-	if addr == "" {
-		return "localhost:6379"
+	return strings.Join(current().Redis.Addrs, " ")
+}
+
+func GetRedisPassword() string {
+	return current().Redis.Password
+}
+
+// GetCallLeaseTTL returns how long a call counts as in progress without a heartbeat
+func GetCallLeaseTTL() time.Duration {
+	return current().Hopper.CallLeaseTTL
+}
+
+// GetPacingMode returns the hopper pacing mode, static or adaptive
+func GetPacingMode() string {
+	return current().Pacing.Mode
+}
+
+// GetTargetAbandonRate returns the abandon rate adaptive pacing aims to stay under
+func GetTargetAbandonRate() float64 {
+	return current().Pacing.TargetAbandonRate
+}
+
+// GetAbandonRateThreshold returns the maximum abandon rate allowed over the compliance period
+func GetAbandonRateThreshold() float64 {
+	return current().Pacing.AbandonRateThreshold
+}
+
+// GetAbandonPeriodDays returns the number of days the abandon rate is measured over
+func GetAbandonPeriodDays() int {
+	return current().Pacing.AbandonPeriodDays
+}
+
+// GetHTTPAddr returns the address the api server listens on
+func GetHTTPAddr() string {
+	return current().HTTP.Addr
+}
+
+// GetQueuedLeadTTL returns how long a lead may wait in a workspace queue before it expires
+func GetQueuedLeadTTL() time.Duration {
+	return current().Hopper.QueuedLeadTTL
+}
+
+// GetReconcileInterval returns how often redis is reconciled with cassandra
+func GetReconcileInterval() time.Duration {
+	return current().Hopper.ReconcileInterval
+}
+
+// GetMaxDialFailures returns how many failed dial attempts move a lead to the dead letter queue
+func GetMaxDialFailures() int {
+	return current().Hopper.MaxDialFailures
+}
+
+// GetRedisMode returns how redis is deployed: standalone, sentinel or cluster
+func GetRedisMode() string {
+	return current().Redis.Mode
+}
+
+// GetRedisAddrs returns the redis nodes, or the sentinels in sentinel mode
+func GetRedisAddrs() []string {
+	return current().Redis.Addrs
+}
+
+// GetRedisMasterName returns the name of the master monitored by the sentinels
+func GetRedisMasterName() string {
+	return current().Redis.MasterName
+}
+
+// GetRedisUsername returns the redis ACL user
+func GetRedisUsername() string {
+	return current().Redis.Username
+}
+
+// GetRedisTLS returns whether redis connections use TLS
+func GetRedisTLS() bool {
+	return current().Redis.TLS
+}
+
+// GetRedisCAFile returns the CA bundle used to verify the redis server certificate
+func GetRedisCAFile() string {
+	return current().Redis.CAFile
+}
+
+// GetRedisTLSServerName returns the server name checked against the redis certificate
+func GetRedisTLSServerName() string {
+	return current().Redis.TLSServerName
+}
+
+// GetCassandraPort returns the port used for contact points without one.
+// Unless configured it is 9142, the usual TLS port, when a CA file is set
+// and 9042 otherwise.
+func GetCassandraPort() int {
+	cfg := current()
+	if cfg.Cassandra.Port != 0 {
+		return cfg.Cassandra.Port
 	}
 
-	return addr
+	if cfg.Cassandra.CAFile != "" {
+		return 9142
+	}
+
+	return 9042
 }
 
-func GetRedisPassword() string {
-	return os.Getenv("REDIS_PASSWORD")
+// GetCassandraTimeout returns the timeout of cassandra queries
+func GetCassandraTimeout() time.Duration {
+	return current().Cassandra.Timeout
+}
+
+// GetCassandraUsername returns the user of cassandra password authentication
+func GetCassandraUsername() string {
+	return current().Cassandra.Username
+}
+
+// GetCassandraPassword returns the password of cassandra password authentication
+func GetCassandraPassword() string {
+	return current().Cassandra.Password
+}
+
+// GetCassandraCAFile returns the CA bundle used to verify cassandra nodes,
+// setting it enables TLS
+func GetCassandraCAFile() string {
+	return current().Cassandra.CAFile
+}
+
+// GetCassandraConsistency returns the consistency level of cassandra queries
+func GetCassandraConsistency() string {
+	return current().Cassandra.Consistency
+}
+
+// GetCassandraLocalDC returns the datacenter queries are routed to, empty
+// routes to every datacenter
+func GetCassandraLocalDC() string {
+	return current().Cassandra.LocalDC
+}
+
+// GetCassandraRetryPolicy returns how failed queries are retried, simple or exponential
+func GetCassandraRetryPolicy() string {
+	return current().Cassandra.RetryPolicy
+}
+
+// GetCassandraNumRetries returns how many times a failed query is retried
+func GetCassandraNumRetries() int {
+	return current().Cassandra.NumRetries
+}
+
+// GetCassandraReconnectInterval returns how often nodes marked down are retried
+func GetCassandraReconnectInterval() time.Duration {
+	return current().Cassandra.ReconnectInterval
+}
+
+// GetCassandraDisableHostLookup returns whether only the contact points are
+// used instead of the nodes they advertise, as Amazon Keyspaces requires
+func GetCassandraDisableHostLookup() bool {
+	return current().Cassandra.DisableHostLookup
+}
+
+// GetTickInterval returns how often the hopper injects leads
+func GetTickInterval() time.Duration {
+	return current().Hopper.TickInterval
+}
+
+// GetInjectionWindow returns how far ahead the hopper injects leads
+func GetInjectionWindow() time.Duration {
+	return current().Hopper.InjectionWindow
+}
+
+// GetDefaultMaxRatePerMin returns the rate of campaigns without a max rate
+func GetDefaultMaxRatePerMin() int {
+	return current().Hopper.DefaultMaxRatePerMin
+}
+
+// GetDefaultInjectCap returns how many leads a campaign gets per cycle
+// without a rate controller
+func GetDefaultInjectCap() int {
+	return current().Hopper.DefaultInjectCap
+}
+
+// GetZipCodeURL returns where zip code data is downloaded from
+func GetZipCodeURL() string {
+	return current().ZipCode.URL
+}
+
+// GetZipCodeDataDir returns the directory zip code data is stored in
+func GetZipCodeDataDir() string {
+	return current().ZipCode.DataDir
+}
+
+// GetLogLevel returns the minimum level logged: debug, info, warn or error
+func GetLogLevel() string {
+	return current().Log.Level
+}
+
+// GetLogFormat returns how logs are written, text or json
+func GetLogFormat() string {
+	return current().Log.Format
+}
+
+// GetTracingEnabled returns whether spans are exported
+func GetTracingEnabled() bool {
+	return current().Tracing.Enabled
+}
+
+// GetOTLPEndpoint returns the host:port of the OTLP/HTTP collector spans are exported to
+func GetOTLPEndpoint() string {
+	return current().Tracing.Endpoint
+}
+
+// GetOTLPInsecure returns whether spans are exported over plain HTTP
+func GetOTLPInsecure() bool {
+	return current().Tracing.Insecure
+}
+
+// GetTracingServiceName returns the service name spans are reported under
+func GetTracingServiceName() string {
+	return current().Tracing.ServiceName
+}
+
+// GetTracingSampleRatio returns the fraction of hopper cycles that are traced
+func GetTracingSampleRatio() float64 {
+	return current().Tracing.SampleRatio
+}
+
+// GetStartupMaxWait returns how long startup retries cassandra and redis
+// before giving up
+func GetStartupMaxWait() time.Duration {
+	return current().Startup.MaxWait
+}
+
+// GetStartupInitialBackoff returns the wait after the first failed connection attempt
+func GetStartupInitialBackoff() time.Duration {
+	return current().Startup.InitialBackoff
+}
+
+// GetStartupMaxBackoff returns the longest wait between two connection attempts
+func GetStartupMaxBackoff() time.Duration {
+	return current().Startup.MaxBackoff
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -136,3 +137,569 @@ func TestGetRedisPASSWORD(t *testing.T) {
 	// clear env
 	os.Unsetenv("REDIS_PASSWORD")
 }
+
+func TestGetCallLeaseTTL(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected time.Duration
+	}{
+		{
+			name:     "default call lease ttl",
+			envValue: "",
+			expected: 10 * time.Minute,
+		},
+
+		{
+			name:     "call lease ttl from env",
+			envValue: "90s",
+			expected: 90 * time.Second,
+		},
+
+		{
+			name:     "invalid call lease ttl",
+			envValue: "soon",
+			expected: 10 * time.Minute,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("CALL_LEASE_TTL", c.envValue)
+			result := GetCallLeaseTTL()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("CALL_LEASE_TTL")
+}
+
+func TestGetPacingMode(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected string
+	}{
+		{
+			name:     "default pacing mode",
+			envValue: "",
+			expected: "static",
+		},
+
+		{
+			name:     "pacing mode from env",
+			envValue: "adaptive",
+			expected: "adaptive",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("PACING_MODE", c.envValue)
+			result := GetPacingMode()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("PACING_MODE")
+}
+
+func TestGetTargetAbandonRate(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected float64
+	}{
+		{
+			name:     "default target abandon rate",
+			envValue: "",
+			expected: 0.03,
+		},
+
+		{
+			name:     "target abandon rate from env",
+			envValue: "0.02",
+			expected: 0.02,
+		},
+
+		{
+			name:     "out of range target abandon rate",
+			envValue: "1.5",
+			expected: 0.03,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("TARGET_ABANDON_RATE", c.envValue)
+			result := GetTargetAbandonRate()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("TARGET_ABANDON_RATE")
+}
+
+func TestGetAbandonRateThreshold(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected float64
+	}{
+		{
+			name:     "default abandon rate threshold",
+			envValue: "",
+			expected: 0.03,
+		},
+
+		{
+			name:     "abandon rate threshold from env",
+			envValue: "0.05",
+			expected: 0.05,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("ABANDON_RATE_THRESHOLD", c.envValue)
+			result := GetAbandonRateThreshold()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("ABANDON_RATE_THRESHOLD")
+}
+
+func TestGetAbandonPeriodDays(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected int
+	}{
+		{
+			name:     "default abandon period",
+			envValue: "",
+			expected: 30,
+		},
+
+		{
+			name:     "abandon period from env",
+			envValue: "7",
+			expected: 7,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("ABANDON_PERIOD_DAYS", c.envValue)
+			result := GetAbandonPeriodDays()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("ABANDON_PERIOD_DAYS")
+}
+
+func TestGetQueuedLeadTTL(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected time.Duration
+	}{
+		{
+			name:     "default queued lead ttl",
+			envValue: "",
+			expected: 30 * time.Minute,
+		},
+
+		{
+			name:     "queued lead ttl from env",
+			envValue: "1h",
+			expected: time.Hour,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("QUEUED_LEAD_TTL", c.envValue)
+			result := GetQueuedLeadTTL()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("QUEUED_LEAD_TTL")
+}
+
+func TestGetReconcileInterval(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected time.Duration
+	}{
+		{
+			name:     "default reconcile interval",
+			envValue: "",
+			expected: 15 * time.Minute,
+		},
+
+		{
+			name:     "reconcile interval from env",
+			envValue: "5m",
+			expected: 5 * time.Minute,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("RECONCILE_INTERVAL", c.envValue)
+			result := GetReconcileInterval()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("RECONCILE_INTERVAL")
+}
+
+func TestGetMaxDialFailures(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected int
+	}{
+		{
+			name:     "default max dial failures",
+			envValue: "",
+			expected: 3,
+		},
+
+		{
+			name:     "max dial failures from env",
+			envValue: "5",
+			expected: 5,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("MAX_DIAL_FAILURES", c.envValue)
+			result := GetMaxDialFailures()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("MAX_DIAL_FAILURES")
+}
+
+func TestGetRedisMode(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected string
+	}{
+		{
+			name:     "default redis mode",
+			envValue: "",
+			expected: "standalone",
+		},
+
+		{
+			name:     "redis mode from env",
+			envValue: "cluster",
+			expected: "cluster",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("REDIS_MODE", c.envValue)
+			result := GetRedisMode()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("REDIS_MODE")
+}
+
+func TestGetRedisAddrs(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected []string
+	}{
+		{
+			name:     "default redis addrs",
+			envValue: "",
+			expected: []string{"localhost:6379"},
+		},
+
+		{
+			name:     "redis addrs from env",
+			envValue: "redis-1:6379 redis-2:6379",
+			expected: []string{"redis-1:6379", "redis-2:6379"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("REDIS_ADDR", c.envValue)
+			result := GetRedisAddrs()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("REDIS_ADDR")
+}
+
+func TestGetRedisTLS(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected bool
+	}{
+		{
+			name:     "default redis tls",
+			envValue: "",
+			expected: false,
+		},
+
+		{
+			name:     "redis tls from env",
+			envValue: "true",
+			expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("REDIS_TLS", c.envValue)
+			result := GetRedisTLS()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("REDIS_TLS")
+}
+
+func TestGetCassandraPort(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected int
+	}{
+		{
+			name:     "default cassandra port",
+			envValue: "",
+			expected: 9042,
+		},
+
+		{
+			name:     "cassandra port from env",
+			envValue: "9142",
+			expected: 9142,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("CASSANDRA_PORT", c.envValue)
+			result := GetCassandraPort()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("CASSANDRA_PORT")
+}
+
+func TestGetCassandraPortWithTLS(t *testing.T) {
+	t.Setenv("CASSANDRA_CA_FILE", "/etc/ssl/cassandra-ca.pem")
+
+	assert.Equal(t, 9142, GetCassandraPort())
+
+	t.Setenv("CASSANDRA_PORT", "9043")
+	assert.Equal(t, 9043, GetCassandraPort())
+}
+
+func TestGetCassandraConsistency(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected string
+	}{
+		{
+			name:     "default cassandra consistency",
+			envValue: "",
+			expected: "QUORUM",
+		},
+
+		{
+			name:     "cassandra consistency from env",
+			envValue: "LOCAL_QUORUM",
+			expected: "LOCAL_QUORUM",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("CASSANDRA_CONSISTENCY", c.envValue)
+			result := GetCassandraConsistency()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("CASSANDRA_CONSISTENCY")
+}
+
+func TestGetCassandraNumRetries(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected int
+	}{
+		{
+			name:     "default cassandra retries",
+			envValue: "",
+			expected: 3,
+		},
+
+		{
+			name:     "cassandra retries disabled from env",
+			envValue: "0",
+			expected: 0,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("CASSANDRA_NUM_RETRIES", c.envValue)
+			result := GetCassandraNumRetries()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("CASSANDRA_NUM_RETRIES")
+}
+
+func TestGetLogFormat(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected string
+	}{
+		{
+			name:     "default log format",
+			envValue: "",
+			expected: "text",
+		},
+
+		{
+			name:     "log format from env",
+			envValue: "json",
+			expected: "json",
+		},
+
+		{
+			name:     "invalid log format",
+			envValue: "xml",
+			expected: "text",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("LOG_FORMAT", c.envValue)
+			result := GetLogFormat()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("LOG_FORMAT")
+}
+
+func TestGetTracingSampleRatio(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected float64
+	}{
+		{
+			name:     "default sample ratio",
+			envValue: "",
+			expected: 1,
+		},
+
+		{
+			name:     "sample ratio from env",
+			envValue: "0.25",
+			expected: 0.25,
+		},
+
+		{
+			name:     "sample ratio above one",
+			envValue: "2",
+			expected: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("TRACING_SAMPLE_RATIO", c.envValue)
+			result := GetTracingSampleRatio()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("TRACING_SAMPLE_RATIO")
+}
+
+func TestGetStartupMaxBackoff(t *testing.T) {
+	cases := []struct {
+		name     string
+		envValue string
+		expected time.Duration
+	}{
+		{
+			name:     "default max backoff",
+			envValue: "",
+			expected: 15 * time.Second,
+		},
+
+		{
+			name:     "max backoff from env",
+			envValue: "1m",
+			expected: time.Minute,
+		},
+
+		{
+			name:     "max backoff below initial backoff",
+			envValue: "100ms",
+			expected: 15 * time.Second,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("STARTUP_MAX_BACKOFF", c.envValue)
+			result := GetStartupMaxBackoff()
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	// clear env
+	os.Unsetenv("STARTUP_MAX_BACKOFF")
+}
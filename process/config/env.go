@@ -0,0 +1,130 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envBinding overrides a configuration value from an environment variable
+type envBinding struct {
+	name string
+	set  func(c *Config, value string) error
+}
+
+// envBindings lists every environment variable read by the process
+var envBindings = []envBinding{
+	fieldsVar("CASSANDRA_CONTACT_POINTS", func(c *Config) *[]string { return &c.Cassandra.ContactPoints }),
+	stringVar("CASSANDRA_KEYSPACE", func(c *Config) *string { return &c.Cassandra.Keyspace }),
+	intVar("CASSANDRA_PORT", func(c *Config) *int { return &c.Cassandra.Port }),
+	durationVar("CASSANDRA_TIMEOUT", func(c *Config) *time.Duration { return &c.Cassandra.Timeout }),
+	stringVar("CASSANDRA_USERNAME", func(c *Config) *string { return &c.Cassandra.Username }),
+	stringVar("CASSANDRA_PASSWORD", func(c *Config) *string { return &c.Cassandra.Password }),
+	stringVar("CASSANDRA_CA_FILE", func(c *Config) *string { return &c.Cassandra.CAFile }),
+	stringVar("CASSANDRA_CONSISTENCY", func(c *Config) *string { return &c.Cassandra.Consistency }),
+	stringVar("CASSANDRA_LOCAL_DC", func(c *Config) *string { return &c.Cassandra.LocalDC }),
+	stringVar("CASSANDRA_RETRY_POLICY", func(c *Config) *string { return &c.Cassandra.RetryPolicy }),
+	intVar("CASSANDRA_NUM_RETRIES", func(c *Config) *int { return &c.Cassandra.NumRetries }),
+	durationVar("CASSANDRA_RECONNECT_INTERVAL", func(c *Config) *time.Duration { return &c.Cassandra.ReconnectInterval }),
+	boolVar("CASSANDRA_DISABLE_HOST_LOOKUP", func(c *Config) *bool { return &c.Cassandra.DisableHostLookup }),
+
+	stringVar("REDIS_MODE", func(c *Config) *string { return &c.Redis.Mode }),
+	fieldsVar("REDIS_ADDR", func(c *Config) *[]string { return &c.Redis.Addrs }),
+	stringVar("REDIS_MASTER_NAME", func(c *Config) *string { return &c.Redis.MasterName }),
+	stringVar("REDIS_USERNAME", func(c *Config) *string { return &c.Redis.Username }),
+	stringVar("REDIS_PASSWORD", func(c *Config) *string { return &c.Redis.Password }),
+	boolVar("REDIS_TLS", func(c *Config) *bool { return &c.Redis.TLS }),
+	stringVar("REDIS_TLS_CA_FILE", func(c *Config) *string { return &c.Redis.CAFile }),
+	stringVar("REDIS_TLS_SERVER_NAME", func(c *Config) *string { return &c.Redis.TLSServerName }),
+
+	stringVar("HTTP_ADDR", func(c *Config) *string { return &c.HTTP.Addr }),
+
+	durationVar("TICK_INTERVAL", func(c *Config) *time.Duration { return &c.Hopper.TickInterval }),
+	durationVar("INJECTION_WINDOW", func(c *Config) *time.Duration { return &c.Hopper.InjectionWindow }),
+	intVar("DEFAULT_MAX_RATE_PER_MIN", func(c *Config) *int { return &c.Hopper.DefaultMaxRatePerMin }),
+	intVar("DEFAULT_INJECT_CAP", func(c *Config) *int { return &c.Hopper.DefaultInjectCap }),
+	durationVar("QUEUED_LEAD_TTL", func(c *Config) *time.Duration { return &c.Hopper.QueuedLeadTTL }),
+	durationVar("CALL_LEASE_TTL", func(c *Config) *time.Duration { return &c.Hopper.CallLeaseTTL }),
+	durationVar("RECONCILE_INTERVAL", func(c *Config) *time.Duration { return &c.Hopper.ReconcileInterval }),
+	intVar("MAX_DIAL_FAILURES", func(c *Config) *int { return &c.Hopper.MaxDialFailures }),
+
+	stringVar("PACING_MODE", func(c *Config) *string { return &c.Pacing.Mode }),
+	floatVar("TARGET_ABANDON_RATE", func(c *Config) *float64 { return &c.Pacing.TargetAbandonRate }),
+	floatVar("ABANDON_RATE_THRESHOLD", func(c *Config) *float64 { return &c.Pacing.AbandonRateThreshold }),
+	intVar("ABANDON_PERIOD_DAYS", func(c *Config) *int { return &c.Pacing.AbandonPeriodDays }),
+
+	stringVar("ZIPCODE_URL", func(c *Config) *string { return &c.ZipCode.URL }),
+	stringVar("ZIPCODE_DATA_DIR", func(c *Config) *string { return &c.ZipCode.DataDir }),
+
+	stringVar("LOG_LEVEL", func(c *Config) *string { return &c.Log.Level }),
+	stringVar("LOG_FORMAT", func(c *Config) *string { return &c.Log.Format }),
+
+	boolVar("TRACING_ENABLED", func(c *Config) *bool { return &c.Tracing.Enabled }),
+	stringVar("OTLP_ENDPOINT", func(c *Config) *string { return &c.Tracing.Endpoint }),
+	boolVar("OTLP_INSECURE", func(c *Config) *bool { return &c.Tracing.Insecure }),
+	stringVar("TRACING_SERVICE_NAME", func(c *Config) *string { return &c.Tracing.ServiceName }),
+	floatVar("TRACING_SAMPLE_RATIO", func(c *Config) *float64 { return &c.Tracing.SampleRatio }),
+
+	durationVar("STARTUP_MAX_WAIT", func(c *Config) *time.Duration { return &c.Startup.MaxWait }),
+	durationVar("STARTUP_INITIAL_BACKOFF", func(c *Config) *time.Duration { return &c.Startup.InitialBackoff }),
+	durationVar("STARTUP_MAX_BACKOFF", func(c *Config) *time.Duration { return &c.Startup.MaxBackoff }),
+}
+
+func stringVar(name string, field func(*Config) *string) envBinding {
+	return envBinding{name, func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}}
+}
+
+// fieldsVar reads a space separated list
+func fieldsVar(name string, field func(*Config) *[]string) envBinding {
+	return envBinding{name, func(c *Config, value string) error {
+		*field(c) = strings.Fields(value)
+		return nil
+	}}
+}
+
+func intVar(name string, field func(*Config) *int) envBinding {
+	return envBinding{name, func(c *Config, value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}}
+}
+
+func floatVar(name string, field func(*Config) *float64) envBinding {
+	return envBinding{name, func(c *Config, value string) error {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}}
+}
+
+func boolVar(name string, field func(*Config) *bool) envBinding {
+	return envBinding{name, func(c *Config, value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}}
+}
+
+func durationVar(name string, field func(*Config) *time.Duration) envBinding {
+	return envBinding{name, func(c *Config, value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}}
+}
@@ -0,0 +1,351 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the whole process configuration. It is read from a YAML or TOML
+// file, by its extension, then every value can be overridden by its
+// environment variable.
+type Config struct {
+	Cassandra CassandraConfig `yaml:"cassandra"`
+	Redis     RedisConfig     `yaml:"redis"`
+	HTTP      HTTPConfig      `yaml:"http"`
+	Hopper    HopperConfig    `yaml:"hopper"`
+	Pacing    PacingConfig    `yaml:"pacing"`
+	ZipCode   ZipCodeConfig   `yaml:"zipcode"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Startup   StartupConfig   `yaml:"startup"`
+}
+
+// CassandraConfig configures the cassandra session
+type CassandraConfig struct {
+	ContactPoints     []string      `yaml:"contact_points"`
+	Keyspace          string        `yaml:"keyspace"`
+	Port              int           `yaml:"port"`
+	Timeout           time.Duration `yaml:"timeout"`
+	Username          string        `yaml:"username"`
+	Password          string        `yaml:"password"`
+	CAFile            string        `yaml:"ca_file"`
+	Consistency       string        `yaml:"consistency"`
+	LocalDC           string        `yaml:"local_dc"`
+	RetryPolicy       string        `yaml:"retry_policy"`
+	NumRetries        int           `yaml:"num_retries"`
+	ReconnectInterval time.Duration `yaml:"reconnect_interval"`
+	DisableHostLookup bool          `yaml:"disable_host_lookup"`
+}
+
+// RedisConfig configures the redis client
+type RedisConfig struct {
+	Mode          string   `yaml:"mode"`
+	Addrs         []string `yaml:"addrs"`
+	MasterName    string   `yaml:"master_name"`
+	Username      string   `yaml:"username"`
+	Password      string   `yaml:"password"`
+	TLS           bool     `yaml:"tls"`
+	CAFile        string   `yaml:"ca_file"`
+	TLSServerName string   `yaml:"tls_server_name"`
+}
+
+// HTTPConfig configures the api server
+type HTTPConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+// HopperConfig configures lead injection and the lifecycle of queued leads
+type HopperConfig struct {
+	TickInterval         time.Duration `yaml:"tick_interval"`
+	InjectionWindow      time.Duration `yaml:"injection_window"`
+	DefaultMaxRatePerMin int           `yaml:"default_max_rate_per_min"`
+	DefaultInjectCap     int           `yaml:"default_inject_cap"`
+	QueuedLeadTTL        time.Duration `yaml:"queued_lead_ttl"`
+	CallLeaseTTL         time.Duration `yaml:"call_lease_ttl"`
+	ReconcileInterval    time.Duration `yaml:"reconcile_interval"`
+	MaxDialFailures      int           `yaml:"max_dial_failures"`
+}
+
+// PacingConfig configures adaptive pacing and abandon rate compliance
+type PacingConfig struct {
+	Mode                 string  `yaml:"mode"`
+	TargetAbandonRate    float64 `yaml:"target_abandon_rate"`
+	AbandonRateThreshold float64 `yaml:"abandon_rate_threshold"`
+	AbandonPeriodDays    int     `yaml:"abandon_period_days"`
+}
+
+// ZipCodeConfig configures where zip code time zones are downloaded and stored
+type ZipCodeConfig struct {
+	URL     string `yaml:"url"`
+	DataDir string `yaml:"data_dir"`
+}
+
+// LogConfig configures the process logger
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// TracingConfig configures the export of OpenTelemetry spans over OTLP
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// StartupConfig configures how long startup waits for cassandra and redis
+type StartupConfig struct {
+	MaxWait        time.Duration `yaml:"max_wait"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// loaded is the configuration stored by Load, nil until Load is called
+var loaded atomic.Pointer[Config]
+
+// Default returns the configuration used when nothing is set
+func Default() Config {
+	return Config{
+		Cassandra: CassandraConfig{
+			ContactPoints:     []string{"cassandra-node1:9042"},
+			Keyspace:          "pluralistmanagement",
+			Timeout:           10 * time.Second,
+			Consistency:       "QUORUM",
+			RetryPolicy:       "simple",
+			NumRetries:        3,
+			ReconnectInterval: time.Minute,
+		},
+		Redis: RedisConfig{
+			Mode:  "standalone",
+			Addrs: []string{"localhost:6379"},
+		},
+		HTTP: HTTPConfig{
+			Addr: ":8080",
+		},
+		Hopper: HopperConfig{
+			TickInterval:         time.Minute,
+			InjectionWindow:      5 * time.Minute,
+			DefaultMaxRatePerMin: 60,
+			DefaultInjectCap:     300,
+			QueuedLeadTTL:        30 * time.Minute,
+			CallLeaseTTL:         10 * time.Minute,
+			ReconcileInterval:    15 * time.Minute,
+			MaxDialFailures:      3,
+		},
+		Pacing: PacingConfig{
+			Mode:                 "static",
+			TargetAbandonRate:    0.03,
+			AbandonRateThreshold: 0.03,
+			AbandonPeriodDays:    30,
+		},
+		ZipCode: ZipCodeConfig{
+			URL:     "http://download.geonames.org/export/zip/US.zip",
+			DataDir: "data",
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Tracing: TracingConfig{
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "process",
+			SampleRatio: 1,
+		},
+		Startup: StartupConfig{
+			MaxWait:        2 * time.Minute,
+			InitialBackoff: time.Second,
+			MaxBackoff:     15 * time.Second,
+		},
+	}
+}
+
+// Load reads the configuration file at path, applies the environment
+// overrides and validates the result. Once loaded, every getter returns the
+// loaded values. An empty path only applies the environment.
+func Load(path string) error {
+	cfg, err := read(path)
+	if err != nil {
+		return err
+	}
+
+	loaded.Store(cfg)
+	return nil
+}
+
+// Current returns the configuration the getters read from
+func Current() Config {
+	return *current()
+}
+
+// decode reads a config file into cfg by its extension: .toml for TOML,
+// .yaml, .yml or none for YAML. The fields only carry yaml tags, so TOML is converted to
+// YAML and goes through the same strict decoding: unknown fields are errors
+// in both formats.
+func decode(path string, data []byte, cfg *Config) error {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		var values map[string]any
+		if err := toml.Unmarshal(data, &values); err != nil {
+			return err
+		}
+
+		converted, err := yaml.Marshal(values)
+		if err != nil {
+			return err
+		}
+		data = converted
+	case ".yaml", ".yml", "":
+	default:
+		return fmt.Errorf("unsupported extension %s, use .yaml, .yml or .toml", ext)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+// read builds a validated configuration from the file at path and the environment
+func read(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+
+		if err := decode(path, data, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+		}
+	}
+
+	var errs []error
+	for _, binding := range envBindings {
+		value := os.Getenv(binding.name)
+		if value == "" {
+			continue
+		}
+
+		if err := binding.set(&cfg, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", binding.name, err))
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return &cfg, nil
+}
+
+// current returns the loaded configuration. Until Load is called it is built
+// from the environment on every call, ignoring invalid values.
+func current() *Config {
+	if cfg := loaded.Load(); cfg != nil {
+		return cfg
+	}
+
+	cfg := Default()
+	for _, binding := range envBindings {
+		value := os.Getenv(binding.name)
+		if value == "" {
+			continue
+		}
+
+		trial := cfg
+		if binding.set(&trial, value) != nil || trial.Validate() != nil {
+			continue
+		}
+		cfg = trial
+	}
+
+	return &cfg
+}
+
+// Validate reports every invalid value of the configuration
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, field, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(len(c.Cassandra.ContactPoints) > 0, "cassandra.contact_points", "at least one contact point is required")
+	check(c.Cassandra.Keyspace != "", "cassandra.keyspace", "is required")
+	check(c.Cassandra.Port >= 0 && c.Cassandra.Port <= 65535, "cassandra.port", "%d is not a valid port", c.Cassandra.Port)
+	check(c.Cassandra.Timeout > 0, "cassandra.timeout", "must be positive")
+	check(oneOf(strings.ToUpper(c.Cassandra.Consistency), "ANY", "ONE", "TWO", "THREE", "QUORUM", "ALL", "LOCAL_QUORUM", "EACH_QUORUM", "LOCAL_ONE"),
+		"cassandra.consistency", "unknown consistency level %q", c.Cassandra.Consistency)
+	check(oneOf(c.Cassandra.RetryPolicy, "simple", "exponential"), "cassandra.retry_policy", "must be simple or exponential, got %q", c.Cassandra.RetryPolicy)
+	check(c.Cassandra.NumRetries >= 0, "cassandra.num_retries", "must not be negative")
+	check(c.Cassandra.ReconnectInterval > 0, "cassandra.reconnect_interval", "must be positive")
+
+	check(oneOf(c.Redis.Mode, "standalone", "sentinel", "cluster"), "redis.mode", "must be standalone, sentinel or cluster, got %q", c.Redis.Mode)
+	check(len(c.Redis.Addrs) > 0, "redis.addrs", "at least one address is required")
+	check(c.Redis.Mode != "sentinel" || c.Redis.MasterName != "", "redis.master_name", "is required in sentinel mode")
+
+	check(c.HTTP.Addr != "", "http.addr", "is required")
+
+	check(c.Hopper.TickInterval > 0, "hopper.tick_interval", "must be positive")
+	check(c.Hopper.InjectionWindow >= time.Minute, "hopper.injection_window", "must be at least one minute")
+	check(c.Hopper.DefaultMaxRatePerMin > 0, "hopper.default_max_rate_per_min", "must be positive")
+	check(c.Hopper.DefaultInjectCap >= 0, "hopper.default_inject_cap", "must not be negative")
+	check(c.Hopper.QueuedLeadTTL > 0, "hopper.queued_lead_ttl", "must be positive")
+	check(c.Hopper.CallLeaseTTL > 0, "hopper.call_lease_ttl", "must be positive")
+	check(c.Hopper.ReconcileInterval > 0, "hopper.reconcile_interval", "must be positive")
+	check(c.Hopper.MaxDialFailures > 0, "hopper.max_dial_failures", "must be positive")
+
+	check(oneOf(c.Pacing.Mode, "static", "adaptive"), "pacing.mode", "must be static or adaptive, got %q", c.Pacing.Mode)
+	check(c.Pacing.TargetAbandonRate >= 0 && c.Pacing.TargetAbandonRate < 1, "pacing.target_abandon_rate", "must be in [0, 1)")
+	check(c.Pacing.AbandonRateThreshold > 0 && c.Pacing.AbandonRateThreshold < 1, "pacing.abandon_rate_threshold", "must be in (0, 1)")
+	check(c.Pacing.AbandonPeriodDays > 0, "pacing.abandon_period_days", "must be positive")
+
+	check(c.ZipCode.URL != "", "zipcode.url", "is required")
+	check(c.ZipCode.DataDir != "", "zipcode.data_dir", "is required")
+
+	check(oneOf(strings.ToLower(c.Log.Level), "debug", "info", "warn", "error"), "log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+	check(oneOf(c.Log.Format, "text", "json"), "log.format", "must be text or json, got %q", c.Log.Format)
+
+	check(!c.Tracing.Enabled || c.Tracing.Endpoint != "", "tracing.endpoint", "is required when tracing is enabled")
+	check(c.Tracing.ServiceName != "", "tracing.service_name", "is required")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be in [0, 1]")
+
+	check(c.Startup.MaxWait >= 0, "startup.max_wait", "must not be negative")
+	check(c.Startup.InitialBackoff > 0, "startup.initial_backoff", "must be positive")
+	check(c.Startup.MaxBackoff >= c.Startup.InitialBackoff, "startup.max_backoff", "must be at least startup.initial_backoff")
+
+	return errors.Join(errs...)
+}
+
+// oneOf reports whether value is one of allowed
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+
+	return false
+}
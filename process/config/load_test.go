@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfig writes a config file in a temporary directory
+func writeConfig(t *testing.T, content string) string {
+	return writeConfigFile(t, "config.yaml", content)
+}
+
+// writeConfigFile writes a config file of the given name in a temporary directory
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// TestLoad tests values are read from the file and overridden by the environment
+func TestLoad(t *testing.T) {
+	defer loaded.Store(nil)
+
+	path := writeConfig(t, `
+cassandra:
+  keyspace: dialer
+redis:
+  mode: cluster
+  addrs: [redis-1:6379, redis-2:6379]
+hopper:
+  tick_interval: 30s
+  default_inject_cap: 100
+`)
+
+	os.Setenv("DEFAULT_INJECT_CAP", "200")
+	defer os.Unsetenv("DEFAULT_INJECT_CAP")
+
+	err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "dialer", GetKeyspace())
+	assert.Equal(t, "cluster", GetRedisMode())
+	assert.Equal(t, []string{"redis-1:6379", "redis-2:6379"}, GetRedisAddrs())
+	assert.Equal(t, 30*time.Second, GetTickInterval())
+	assert.Equal(t, 200, GetDefaultInjectCap())
+	assert.Equal(t, 5*time.Minute, GetInjectionWindow())
+}
+
+// TestLoadInvalid tests every invalid value is reported and nothing is loaded
+func TestLoadInvalid(t *testing.T) {
+	defer loaded.Store(nil)
+
+	path := writeConfig(t, `
+cassandra:
+  consistency: MOST
+redis:
+  mode: sentinel
+pacing:
+  target_abandon_rate: 2
+`)
+
+	os.Setenv("CASSANDRA_PORT", "ninety")
+	defer os.Unsetenv("CASSANDRA_PORT")
+
+	err := Load(path)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "CASSANDRA_PORT")
+	assert.Contains(t, err.Error(), "cassandra.consistency")
+	assert.Contains(t, err.Error(), "redis.master_name")
+	assert.Contains(t, err.Error(), "pacing.target_abandon_rate")
+	assert.Nil(t, loaded.Load())
+}
+
+// TestLoadUnknownField tests typos in the file are rejected
+func TestLoadUnknownField(t *testing.T) {
+	defer loaded.Store(nil)
+
+	path := writeConfig(t, `
+hopper:
+  tick_intervall: 30s
+`)
+
+	err := Load(path)
+	assert.Error(t, err)
+}
+
+// TestLoadTOML tests a .toml file is read like its YAML equivalent
+func TestLoadTOML(t *testing.T) {
+	defer loaded.Store(nil)
+
+	path := writeConfigFile(t, "config.toml", `
+[cassandra]
+keyspace = "dialer"
+
+[redis]
+mode = "cluster"
+addrs = ["redis-1:6379", "redis-2:6379"]
+
+[hopper]
+tick_interval = "30s"
+default_inject_cap = 100
+`)
+
+	err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "dialer", GetKeyspace())
+	assert.Equal(t, "cluster", GetRedisMode())
+	assert.Equal(t, []string{"redis-1:6379", "redis-2:6379"}, GetRedisAddrs())
+	assert.Equal(t, 30*time.Second, GetTickInterval())
+	assert.Equal(t, 100, GetDefaultInjectCap())
+}
+
+// TestLoadTOMLUnknownField tests typos are rejected in TOML files as well
+func TestLoadTOMLUnknownField(t *testing.T) {
+	defer loaded.Store(nil)
+
+	path := writeConfigFile(t, "config.toml", `
+[hopper]
+tick_intervall = "30s"
+`)
+
+	err := Load(path)
+	assert.Error(t, err)
+}
+
+// TestLoadUnsupportedExtension tests a file of another format is rejected
+func TestLoadUnsupportedExtension(t *testing.T) {
+	defer loaded.Store(nil)
+
+	path := writeConfigFile(t, "config.json", `{}`)
+
+	err := Load(path)
+	assert.ErrorContains(t, err, "unsupported extension .json")
+}
+
+// TestReload tests only reloadable values are taken from a changed file
+func TestReload(t *testing.T) {
+	cur := Default()
+	next := Default()
+	next.Hopper.TickInterval = 10 * time.Second
+	next.Hopper.DefaultMaxRatePerMin = 120
+
+	reloaded, restart := reload(cur, next)
+	assert.Equal(t, 10*time.Second, reloaded.Hopper.TickInterval)
+	assert.Equal(t, 120, reloaded.Hopper.DefaultMaxRatePerMin)
+	assert.False(t, restart)
+
+	next.Redis.Addrs = []string{"redis-2:6379"}
+	reloaded, restart = reload(cur, next)
+	assert.Equal(t, []string{"localhost:6379"}, reloaded.Redis.Addrs)
+	assert.True(t, restart)
+}
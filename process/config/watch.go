@@ -0,0 +1,76 @@
+package config
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/nico-phil/process/logging"
+)
+
+var logger = logging.For("config")
+
+// Watch reloads the configuration file at path whenever it changes, checking
+// every interval until ctx is done. Only values that are read on every use
+// are reloaded, changes to anything else are logged and need a restart. An
+// invalid file is logged and the current configuration is kept.
+func Watch(ctx context.Context, path string, interval time.Duration) {
+	modTime := fileModTime(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed := fileModTime(path)
+		if changed.Equal(modTime) {
+			continue
+		}
+		modTime = changed
+
+		next, err := read(path)
+		if err != nil {
+			logger.Error("ignoring invalid config file", "path", path, "error", err)
+			continue
+		}
+
+		reloaded, restart := reload(*current(), *next)
+		loaded.Store(&reloaded)
+
+		logger.Info("reloaded config file", "path", path)
+		if restart {
+			logger.Warn("some config changes only apply after a restart", "path", path)
+		}
+	}
+}
+
+// reload returns cur with the reloadable values of next, and whether next
+// changes other values as well
+func reload(cur, next Config) (Config, bool) {
+	reloaded := cur
+	reloaded.Hopper.TickInterval = next.Hopper.TickInterval
+	reloaded.Hopper.InjectionWindow = next.Hopper.InjectionWindow
+	reloaded.Hopper.DefaultMaxRatePerMin = next.Hopper.DefaultMaxRatePerMin
+	reloaded.Hopper.DefaultInjectCap = next.Hopper.DefaultInjectCap
+	reloaded.Hopper.QueuedLeadTTL = next.Hopper.QueuedLeadTTL
+	reloaded.Hopper.MaxDialFailures = next.Hopper.MaxDialFailures
+
+	restart := !reflect.DeepEqual(reloaded, next)
+	return reloaded, restart
+}
+
+// fileModTime returns the modification time of path, zero if it cannot be read
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
@@ -0,0 +1,274 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nico-phil/process/logging"
+)
+
+// AuditEvent is an entry of the audit history of a workspace. Events are
+// partitioned by workspace and UTC day and never updated. Campaign and list
+// wide events, like a campaign out of its schedule, have no lead. Each event
+// is also written by lead, or by campaign when it has no lead, for the trail
+// of a lead.
+type AuditEvent struct {
+	WorkspaceID string    `cql:"workspace_id" json:"workspace_id"`
+	ID          string    `cql:"event_id" json:"id"`
+	At          time.Time `json:"at"`
+	Type        string    `cql:"event_type" json:"type"`
+	Reason      string    `cql:"reason" json:"reason,omitempty"`
+	CampaignID  string    `cql:"campaign_id" json:"campaign_id,omitempty"`
+	ListNumber  string    `cql:"listnumber" json:"list_number,omitempty"`
+	LeadID      string    `cql:"leadid" json:"lead_id,omitempty"`
+	CycleID     string    `cql:"cycle_id" json:"cycle_id,omitempty"`
+	Detail      string    `cql:"detail" json:"detail,omitempty"`
+}
+
+const (
+	// AuditInjected is a lead queued by the hopper
+	AuditInjected = "injected"
+	// AuditSkipped is a lead, list or campaign the hopper did not inject, or a
+	// dialer did not call, with the reason why
+	AuditSkipped = "skipped"
+	// AuditDequeued is a lead taken from the queue by a dialer
+	AuditDequeued = "dequeued"
+	// AuditDisposition is the outcome of a call, the disposition is the reason
+	AuditDisposition = "disposition"
+	// AuditPaused is a campaign paused by an operator
+	AuditPaused = "paused"
+	// AuditResumed is a paused campaign resumed by an operator
+	AuditResumed = "resumed"
+)
+
+const (
+	// AuditReasonOutsideWindow means the campaign dialing window excluded the
+	// lead, by the campaign schedule or in the lead's local time
+	AuditReasonOutsideWindow = "outside_window"
+	// AuditReasonRateExhausted means the campaign had no injection budget left
+	AuditReasonRateExhausted = "rate_exhausted"
+	// AuditReasonBadPhone means the phone number cannot be dialed
+	AuditReasonBadPhone = "bad_phone"
+	// AuditReasonExpired means the lead stayed queued longer than the queued lead TTL
+	AuditReasonExpired = "expired"
+)
+
+// auditDay returns the partition day of t, days are in UTC
+func auditDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// RecordAuditEvents appends events to the audit history. Events without an
+// id get a time based one from At, or from now when At is not set. Events of
+// a partition are written in a single batch.
+func RecordAuditEvents(ctx context.Context, events ...AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	session := Getsession()
+	if session == nil {
+		return ErrNoConnection
+	}
+
+	query := "INSERT INTO audit_events (workspace_id, day, event_id, event_type, reason, campaign_id, listnumber, leadid, cycle_id, detail) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	byLeadQuery := "INSERT INTO audit_events_by_lead (workspace_id, leadid, day, event_id, event_type, reason, campaign_id, listnumber, cycle_id, detail) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	byCampaignQuery := "INSERT INTO audit_events_by_campaign (workspace_id, campaign_id, day, event_id, event_type, reason, listnumber, cycle_id, detail) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+	batches := map[string]*gocql.Batch{}
+	batch := func(partition string) *gocql.Batch {
+		if _, ok := batches[partition]; !ok {
+			batches[partition] = session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+		}
+		return batches[partition]
+	}
+
+	for _, event := range events {
+		if event.At.IsZero() {
+			event.At = time.Now()
+		}
+
+		eventID := gocql.UUIDFromTime(event.At)
+		if event.ID != "" {
+			parsed, err := gocql.ParseUUID(event.ID)
+			if err != nil {
+				return fmt.Errorf("db: invalid audit event id %s: %w", event.ID, err)
+			}
+			eventID = parsed
+		}
+
+		day := auditDay(eventID.Time())
+		batch(event.WorkspaceID+"/"+day.Format(time.DateOnly)).Query(query, event.WorkspaceID, day, eventID, event.Type, event.Reason,
+			event.CampaignID, event.ListNumber, event.LeadID, event.CycleID, event.Detail)
+
+		switch {
+		case event.LeadID != "":
+			batch("lead/"+event.WorkspaceID+"/"+event.LeadID).Query(byLeadQuery, event.WorkspaceID, event.LeadID, day, eventID, event.Type, event.Reason,
+				event.CampaignID, event.ListNumber, event.CycleID, event.Detail)
+		case event.CampaignID != "":
+			batch("campaign/"+event.WorkspaceID+"/"+event.CampaignID+"/"+day.Format(time.DateOnly)).Query(byCampaignQuery, event.WorkspaceID, event.CampaignID, day, eventID,
+				event.Type, event.Reason, event.ListNumber, event.CycleID, event.Detail)
+		}
+	}
+
+	for partition, batch := range batches {
+		if err := session.ExecuteBatch(batch); err != nil {
+			logger.Error("failed to record audit events", "partition", partition, "count", batch.Size(), "error", err)
+			return fmt.Errorf("db: failed to record audit events for %s: %w", partition, err)
+		}
+	}
+
+	return nil
+}
+
+// GetAuditEvents returns the audit events of a workspace on the UTC day of
+// day, newest first
+func GetAuditEvents(ctx context.Context, workspaceID string, day time.Time) ([]AuditEvent, error) {
+	session := Getsession()
+	if session == nil {
+		return nil, ErrNoConnection
+	}
+
+	query := "SELECT event_id, event_type, reason, campaign_id, listnumber, leadid, cycle_id, detail FROM audit_events WHERE workspace_id = ? AND day = ?"
+	scanner := session.Query(query, workspaceID, auditDay(day)).WithContext(ctx).Iter().Scanner()
+
+	events := []AuditEvent{}
+	for scanner.Next() {
+		var eventID gocql.UUID
+		event := AuditEvent{WorkspaceID: workspaceID}
+		err := scanner.Scan(
+			&eventID,
+			&event.Type,
+			&event.Reason,
+			&event.CampaignID,
+			&event.ListNumber,
+			&event.LeadID,
+			&event.CycleID,
+			&event.Detail,
+		)
+		if err != nil {
+			logger.Error("failed to read audit event", logging.WorkspaceID, workspaceID, "error", err)
+			return nil, fmt.Errorf("db: failed to get audit events for workspace %s: %w", workspaceID, err)
+		}
+
+		event.ID = eventID.String()
+		event.At = eventID.Time()
+		events = append(events, event)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("db: failed to close iterator: %s : %w", workspaceID, err)
+	}
+
+	return events, nil
+}
+
+// GetLeadAuditEvents returns the events of a lead of a workspace on the UTC
+// day of day, newest first, read from its own partition
+func GetLeadAuditEvents(ctx context.Context, workspaceID, leadID string, day time.Time) ([]AuditEvent, error) {
+	session := Getsession()
+	if session == nil {
+		return nil, ErrNoConnection
+	}
+
+	query := "SELECT event_id, event_type, reason, campaign_id, listnumber, cycle_id, detail FROM audit_events_by_lead WHERE workspace_id = ? AND leadid = ? AND day = ?"
+	scanner := session.Query(query, workspaceID, leadID, auditDay(day)).WithContext(ctx).Iter().Scanner()
+
+	events := []AuditEvent{}
+	for scanner.Next() {
+		var eventID gocql.UUID
+		event := AuditEvent{WorkspaceID: workspaceID, LeadID: leadID}
+		err := scanner.Scan(
+			&eventID,
+			&event.Type,
+			&event.Reason,
+			&event.CampaignID,
+			&event.ListNumber,
+			&event.CycleID,
+			&event.Detail,
+		)
+		if err != nil {
+			logger.Error("failed to read lead audit event", logging.WorkspaceID, workspaceID, logging.LeadID, leadID, "error", err)
+			return nil, fmt.Errorf("db: failed to get audit events for lead %s: %w", leadID, err)
+		}
+
+		event.ID = eventID.String()
+		event.At = eventID.Time()
+		events = append(events, event)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("db: failed to close iterator: %s : %w", leadID, err)
+	}
+
+	return events, nil
+}
+
+// GetCampaignAuditEvents returns the campaign and list wide events of a
+// campaign of a workspace on the UTC day of day, newest first
+func GetCampaignAuditEvents(ctx context.Context, workspaceID, campaignID string, day time.Time) ([]AuditEvent, error) {
+	session := Getsession()
+	if session == nil {
+		return nil, ErrNoConnection
+	}
+
+	query := "SELECT event_id, event_type, reason, listnumber, cycle_id, detail FROM audit_events_by_campaign WHERE workspace_id = ? AND campaign_id = ? AND day = ?"
+	scanner := session.Query(query, workspaceID, campaignID, auditDay(day)).WithContext(ctx).Iter().Scanner()
+
+	events := []AuditEvent{}
+	for scanner.Next() {
+		var eventID gocql.UUID
+		event := AuditEvent{WorkspaceID: workspaceID, CampaignID: campaignID}
+		err := scanner.Scan(
+			&eventID,
+			&event.Type,
+			&event.Reason,
+			&event.ListNumber,
+			&event.CycleID,
+			&event.Detail,
+		)
+		if err != nil {
+			logger.Error("failed to read campaign audit event", logging.WorkspaceID, workspaceID, logging.CampaignID, campaignID, "error", err)
+			return nil, fmt.Errorf("db: failed to get audit events for campaign %s: %w", campaignID, err)
+		}
+
+		event.ID = eventID.String()
+		event.At = eventID.Time()
+		events = append(events, event)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("db: failed to close iterator: %s : %w", campaignID, err)
+	}
+
+	return events, nil
+}
+
+// LeadAuditTrail keeps the events explaining what happened to a lead: its
+// own events and the campaign and list wide ones that applied to it. A
+// campaign wide event applies when it names campaignID, a list wide one when
+// it names listNumber.
+func LeadAuditTrail(events []AuditEvent, campaignID, listNumber, leadID string) []AuditEvent {
+	trail := []AuditEvent{}
+	for _, event := range events {
+		switch {
+		case event.LeadID != "":
+			if event.LeadID != leadID || (event.ListNumber != "" && event.ListNumber != listNumber) {
+				continue
+			}
+		case event.ListNumber != "":
+			if event.ListNumber != listNumber {
+				continue
+			}
+		case event.CampaignID == "" || event.CampaignID != campaignID:
+			continue
+		}
+
+		trail = append(trail, event)
+	}
+
+	return trail
+}
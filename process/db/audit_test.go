@@ -0,0 +1,39 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAuditDay tests events are partitioned by UTC day
+func TestAuditDay(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+
+	// 22:00 in New York is 03:00 the next day in UTC
+	day := auditDay(time.Date(2025, 3, 10, 22, 0, 0, 0, loc))
+	assert.Equal(t, time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC), day)
+}
+
+// TestLeadAuditTrail tests a lead trail keeps its own events and the
+// campaign and list wide ones that applied to it
+func TestLeadAuditTrail(t *testing.T) {
+	events := []AuditEvent{
+		{ID: "1", Type: AuditSkipped, Reason: AuditReasonOutsideWindow, CampaignID: "c1"},
+		{ID: "2", Type: AuditSkipped, Reason: AuditReasonRateExhausted, CampaignID: "c2"},
+		{ID: "3", Type: AuditInjected, CampaignID: "c1", ListNumber: "100", LeadID: "lead-1"},
+		{ID: "4", Type: AuditInjected, CampaignID: "c1", ListNumber: "100", LeadID: "lead-2"},
+		{ID: "5", Type: AuditInjected, CampaignID: "c1", ListNumber: "200", LeadID: "lead-1"},
+		{ID: "6", Type: AuditSkipped, Reason: "daily_cap_reached", CampaignID: "c1", ListNumber: "100"},
+		{ID: "7", Type: AuditDisposition, Reason: "no_answer", ListNumber: "100", LeadID: "lead-1"},
+	}
+
+	trail := LeadAuditTrail(events, "c1", "100", "lead-1")
+
+	ids := []string{}
+	for _, event := range trail {
+		ids = append(ids, event.ID)
+	}
+	assert.Equal(t, []string{"1", "3", "6", "7"}, ids)
+}
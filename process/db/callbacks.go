@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nico-phil/process/logging"
+)
+
+var (
+	ErrCallbackNotFound = errors.New("callback not found")
+)
+
+// callbackRetention is how long a callback is kept after its due time
+const callbackRetention = 30 * 24 * time.Hour
+
+// callbackTTL returns the TTL in seconds of a callback row written at now: it
+// lives until callbackRetention after it is due
+func callbackTTL(dueAt, now time.Time) int {
+	return max(int(dueAt.Add(callbackRetention).Sub(now).Seconds()), 1)
+}
+
+// CreateCallback stores a new pending callback and returns it with its ID. The
+// callback expires callbackRetention after it is due.
+func CreateCallback(callback Callback) (*Callback, error) {
+	session := Getsession()
+	if session == nil {
+		return nil, ErrNoConnection
+	}
+
+	now := time.Now()
+	callback.ID = gocql.TimeUUID().String()
+	callback.Status = CallbackPending
+	callback.CreatedAt = &now
+
+	query := "INSERT INTO callbacks (workspace_id, due_at, callback_id, campaign_id, listnumber, leadid, agent_id, status, createdat) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) USING TTL ?"
+	err := session.Query(query,
+		callback.WorkspaceID, callback.DueAt, callback.ID, callback.CampaignID, callback.ListNumber,
+		callback.LeadID, callback.AgentID, callback.Status, callback.CreatedAt, callbackTTL(callback.DueAt, now),
+	).Exec()
+	if err != nil {
+		logger.Error("failed to create callback", logging.WorkspaceID, callback.WorkspaceID, logging.LeadID, callback.LeadID, "error", err)
+		return nil, fmt.Errorf("db: failed to create callback: %w", err)
+	}
+
+	logger.Debug("created callback", logging.WorkspaceID, callback.WorkspaceID, logging.LeadID, callback.LeadID, "callback_id", callback.ID, "due_at", callback.DueAt)
+	return &callback, nil
+}
+
+// GetCallback retrieves a callback by ID
+func GetCallback(workspaceID, callbackID string) (*Callback, error) {
+	session := Getsession()
+	if session == nil {
+		return nil, ErrNoConnection
+	}
+
+	query := "SELECT callback_id, workspace_id, campaign_id, listnumber, leadid, agent_id, due_at, status, createdat FROM callbacks WHERE workspace_id = ? AND callback_id = ? ALLOW FILTERING"
+
+	var callback Callback
+	err := session.Query(query, workspaceID, callbackID).Scan(
+		&callback.ID, &callback.WorkspaceID, &callback.CampaignID, &callback.ListNumber,
+		&callback.LeadID, &callback.AgentID, &callback.DueAt, &callback.Status, &callback.CreatedAt,
+	)
+	if err == gocql.ErrNotFound {
+		return nil, ErrCallbackNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("db: failed to get callback %s: %w", callbackID, err)
+	}
+
+	return &callback, nil
+}
+
+// GetDueCallbacks returns the pending callbacks of a workspace due between
+// dueAfter and dueBefore. The lower bound keeps the read to the recent part
+// of the partition instead of every callback ever scheduled.
+func GetDueCallbacks(ctx context.Context, workspaceID string, dueAfter, dueBefore time.Time) ([]Callback, error) {
+	session := Getsession()
+	if session == nil {
+		return nil, ErrNoConnection
+	}
+
+	query := "SELECT callback_id, workspace_id, campaign_id, listnumber, leadid, agent_id, due_at, status, createdat FROM callbacks WHERE workspace_id = ? AND due_at > ? AND due_at <= ?"
+	scanner := session.Query(query, workspaceID, dueAfter, dueBefore).WithContext(ctx).Iter().Scanner()
+
+	var callbacks []Callback
+	for scanner.Next() {
+		var callback Callback
+		err := scanner.Scan(
+			&callback.ID,
+			&callback.WorkspaceID,
+			&callback.CampaignID,
+			&callback.ListNumber,
+			&callback.LeadID,
+			&callback.AgentID,
+			&callback.DueAt,
+			&callback.Status,
+			&callback.CreatedAt,
+		)
+		if err != nil {
+			logger.Error("failed to read callback", logging.WorkspaceID, workspaceID, "error", err)
+			return nil, fmt.Errorf("db: failed to get callbacks for workspace: %s : %w", workspaceID, err)
+		}
+
+		if callback.Status == CallbackPending {
+			callbacks = append(callbacks, callback)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("db: failed to close iterator: %s : %w", workspaceID, err)
+	}
+
+	return callbacks, nil
+}
+
+// UpdateCallbackStatus updates the status of a callback, the status expires
+// with the rest of the row
+func UpdateCallbackStatus(ctx context.Context, callback Callback, status string) error {
+	session := Getsession()
+	if session == nil {
+		return ErrNoConnection
+	}
+
+	query := "UPDATE callbacks USING TTL ? SET status = ? WHERE workspace_id = ? AND due_at = ? AND callback_id = ?"
+	ttl := callbackTTL(callback.DueAt, time.Now())
+	if err := session.Query(query, ttl, status, callback.WorkspaceID, callback.DueAt, callback.ID).WithContext(ctx).Exec(); err != nil {
+		logger.Error("failed to update callback status", logging.WorkspaceID, callback.WorkspaceID, "callback_id", callback.ID, "error", err)
+		return err
+	}
+
+	logger.Debug("updated callback status", logging.WorkspaceID, callback.WorkspaceID, "callback_id", callback.ID, "status", status)
+	return nil
+}
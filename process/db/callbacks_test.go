@@ -0,0 +1,18 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCallbackTTL tests callbacks are kept until the retention after they
+// are due, and never get a TTL of zero, which would keep them forever
+func TestCallbackTTL(t *testing.T) {
+	now := time.Now()
+
+	assert.Equal(t, int((callbackRetention + time.Hour).Seconds()), callbackTTL(now.Add(time.Hour), now))
+	assert.Equal(t, int(time.Hour.Seconds()), callbackTTL(now.Add(-callbackRetention+time.Hour), now))
+	assert.Equal(t, 1, callbackTTL(now.Add(-2*callbackRetention), now))
+}
@@ -1,48 +1,169 @@
 package db
 
 import (
+	"context"
 	"errors"
-	"log"
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/gocql/gocql"
 	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/logging"
 )
 
 var (
-	ErrNoConnection = errors.New("no database connection ")
+	ErrNoConnection     = errors.New("no database connection ")
+	ErrCampaignNotFound = errors.New("campaign not found")
 )
 
-var session *gocql.Session
+// current is the session every query runs on, it is replaced when the
+// connection is re-established
+var current atomic.Pointer[gocql.Session]
 
-// NewClient created a new cassandra connection
+// reconnectAfterFailures is the number of failed pings in a row after which
+// KeepAlive replaces the session
+const reconnectAfterFailures = 3
+
+var logger = logging.For("db")
+
+// NewClient created a new cassandra connection. A session created before is
+// closed once the new one is in use, after the query timeout so the queries
+// still running on it can finish.
 func NewClient() error {
+	cluster, err := newCluster()
+	if err != nil {
+		return fmt.Errorf("invalid cassandra configuration: %v", err)
+	}
+
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return fmt.Errorf("failed to connect to cassandra: %v", err)
+	}
+
+	if old := current.Swap(session); old != nil {
+		time.AfterFunc(cluster.Timeout, old.Close)
+	}
 
+	logger.Info("connected to cassandra", "keyspace", cluster.Keyspace)
+	return nil
+}
+
+// newCluster builds the cluster configuration from config
+func newCluster() (*gocql.ClusterConfig, error) {
 	contactPoints := config.GetContactPoints()
 	keypsace := config.GetKeyspace()
 
 	cluster := gocql.NewCluster(contactPoints...)
 	cluster.Keyspace = keypsace
-	cluster.Port = 9142
-	cluster.Timeout = 10 * time.Second
+	cluster.Port = config.GetCassandraPort()
+	cluster.Timeout = config.GetCassandraTimeout()
+	cluster.ReconnectInterval = config.GetCassandraReconnectInterval()
+	cluster.DisableInitialHostLookup = config.GetCassandraDisableHostLookup()
+	cluster.QueryObserver = queryObserver{}
+	cluster.BatchObserver = queryObserver{}
 
-	var err error
-	session, err = cluster.CreateSession()
+	consistency, err := gocql.ParseConsistencyWrapper(config.GetCassandraConsistency())
 	if err != nil {
-		log.Printf("failed to connect to cassandra %v", err)
-		return err
+		return nil, err
+	}
+	cluster.Consistency = consistency
+
+	if username := config.GetCassandraUsername(); username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: username,
+			Password: config.GetCassandraPassword(),
+		}
+	}
+
+	if caFile := config.GetCassandraCAFile(); caFile != "" {
+		cluster.SslOpts = &gocql.SslOptions{
+			CaPath:                 caFile,
+			EnableHostVerification: true,
+		}
+	}
+
+	if localDC := config.GetCassandraLocalDC(); localDC != "" {
+		cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.DCAwareRoundRobinPolicy(localDC))
+	}
+
+	retries := config.GetCassandraNumRetries()
+	switch policy := config.GetCassandraRetryPolicy(); policy {
+	case "simple":
+		cluster.RetryPolicy = &gocql.SimpleRetryPolicy{NumRetries: retries}
+	case "exponential":
+		cluster.RetryPolicy = &gocql.ExponentialBackoffRetryPolicy{
+			NumRetries: retries,
+			Min:        100 * time.Millisecond,
+			Max:        10 * time.Second,
+		}
+	default:
+		return nil, fmt.Errorf("unknown cassandra retry policy %q", policy)
+	}
+
+	return cluster, nil
+}
+
+// Ping checks the cassandra session answers a query
+func Ping(ctx context.Context) error {
+	session := Getsession()
+	if session == nil || session.Closed() {
+		return ErrNoConnection
+	}
+
+	var version string
+	return session.Query("SELECT release_version FROM system.local").WithContext(ctx).Scan(&version)
+}
+
+// KeepAlive pings cassandra every interval until ctx is done. gocql reconnects
+// to hosts that come back on its own, but a session whose hosts all changed
+// address, e.g. after the containers restarted, never recovers. After a few
+// failed pings in a row a new session is created from the contact points.
+func KeepAlive(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, config.GetCassandraTimeout())
+		err := Ping(pingCtx)
+		cancel()
+		if err == nil {
+			if failures > 0 {
+				logger.Info("cassandra connection restored", "failures", failures)
+			}
+			failures = 0
+			continue
+		}
+
+		failures++
+		logger.Warn("cassandra ping failed", "failures", failures, "error", err)
+		if failures < reconnectAfterFailures {
+			continue
+		}
+
+		if err := NewClient(); err != nil {
+			logger.Error("failed to reconnect to cassandra", "error", err)
+			continue
+		}
+		failures = 0
 	}
-	log.Printf("successfully connected to db")
-	return nil
 }
 
+// Getsession returns the session queries run on, nil before NewClient succeeded
 func Getsession() *gocql.Session {
-	return session
+	return current.Load()
 }
 
 func CloseSession() {
-	if session != nil {
+	if session := Getsession(); session != nil {
 		session.Close()
-		log.Println("Cassandra connection closed")
+		logger.Info("cassandra connection closed")
 	}
 }
@@ -1,14 +1,17 @@
 package db
 
 import (
+	"os"
 	"testing"
 
+	"github.com/gocql/gocql"
+	"github.com/nico-phil/process/config"
 	"github.com/stretchr/testify/assert"
 )
 
 // TestGetSession tests db session
 func TestGetSession(t *testing.T) {
-	session = nil
+	current.Store(nil)
 	result := Getsession()
 	assert.Nil(t, result)
 }
@@ -16,7 +19,7 @@ func TestGetSession(t *testing.T) {
 // TestCloseSession tests close db session
 func TestCloseSession(t *testing.T) {
 	// Test closing when session is nil
-	session = nil
+	current.Store(nil)
 
 	//should not panic
 	CloseSession()
@@ -24,3 +27,42 @@ func TestCloseSession(t *testing.T) {
 	// Test is primarily to ensure no panic accurs
 	assert.True(t, true)
 }
+
+// TestNewCluster tests the cluster configuration is read from config
+func TestNewCluster(t *testing.T) {
+	os.Setenv("CASSANDRA_CONSISTENCY", "LOCAL_QUORUM")
+	os.Setenv("CASSANDRA_USERNAME", "user")
+	os.Setenv("CASSANDRA_PASSWORD", "secret")
+	os.Setenv("CASSANDRA_CA_FILE", "/AmazonRootCA1.pem")
+	os.Setenv("CASSANDRA_RETRY_POLICY", "exponential")
+	defer func() {
+		os.Unsetenv("CASSANDRA_CONSISTENCY")
+		os.Unsetenv("CASSANDRA_USERNAME")
+		os.Unsetenv("CASSANDRA_PASSWORD")
+		os.Unsetenv("CASSANDRA_CA_FILE")
+		os.Unsetenv("CASSANDRA_RETRY_POLICY")
+	}()
+
+	cluster, err := newCluster()
+	assert.NoError(t, err)
+	assert.Equal(t, gocql.LocalQuorum, cluster.Consistency)
+	assert.Equal(t, gocql.PasswordAuthenticator{Username: "user", Password: "secret"}, cluster.Authenticator)
+	assert.Equal(t, "/AmazonRootCA1.pem", cluster.SslOpts.CaPath)
+	assert.Equal(t, 9142, cluster.Port)
+	assert.IsType(t, &gocql.ExponentialBackoffRetryPolicy{}, cluster.RetryPolicy)
+}
+
+// TestNewClusterInvalidConsistency tests an unknown consistency level is
+// rejected when the configuration is loaded, and never reaches the cluster
+func TestNewClusterInvalidConsistency(t *testing.T) {
+	os.Setenv("CASSANDRA_CONSISTENCY", "MOST")
+	defer os.Unsetenv("CASSANDRA_CONSISTENCY")
+
+	err := config.Load("")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cassandra.consistency")
+
+	cluster, err := newCluster()
+	assert.NoError(t, err)
+	assert.Equal(t, gocql.Quorum, cluster.Consistency)
+}
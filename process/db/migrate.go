@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrations holds the schema changes of the tables this process reads and
+// writes, applied in file name order by Migrate
+//
+//go:embed migrations/*.cql
+var migrations embed.FS
+
+// Migration is a schema change of the keyspace
+type Migration struct {
+	Version int
+	Name    string
+	// Statements are the CQL statements of the migration, without the
+	// trailing semicolon
+	Statements []string
+}
+
+// LoadMigrations returns every migration, oldest first. Files are named
+// <version>_<name>.cql.
+func LoadMigrations() ([]Migration, error) {
+	files, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var loaded []Migration
+	for _, file := range files {
+		base := strings.TrimSuffix(file.Name(), ".cql")
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s", file.Name())
+		}
+
+		data, err := migrations.ReadFile(path.Join("migrations", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		loaded = append(loaded, Migration{Version: version, Name: name, Statements: splitStatements(string(data))})
+	}
+
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].Version < loaded[j].Version })
+	return loaded, nil
+}
+
+// splitStatements splits a CQL file on semicolons, dropping comment lines
+func splitStatements(cql string) []string {
+	var lines []string
+	for _, line := range strings.Split(cql, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	var statements []string
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+
+	return statements
+}
+
+// Migrate applies the migrations that have not been applied to the keyspace
+// yet and returns them. Applied versions are recorded in schema_migrations.
+// Adding a column that already exists is not an error, so a keyspace whose
+// tables were changed by hand can be brought under migrations.
+func Migrate(ctx context.Context) ([]Migration, error) {
+	session := Getsession()
+	if session == nil {
+		return nil, ErrNoConnection
+	}
+
+	all, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	err = session.Query("CREATE TABLE IF NOT EXISTS schema_migrations (version int PRIMARY KEY, name text, applied_at timestamp)").WithContext(ctx).Exec()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+
+	applied := map[int]bool{}
+	scanner := session.Query("SELECT version FROM schema_migrations").WithContext(ctx).Iter().Scanner()
+	for scanner.Next() {
+		var version int
+		if err := scanner.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+
+	var ran []Migration
+	for _, migration := range all {
+		if applied[migration.Version] {
+			continue
+		}
+
+		for _, statement := range migration.Statements {
+			err := session.Query(statement).WithContext(ctx).Exec()
+			if err != nil && !strings.Contains(err.Error(), "conflicts with an existing column") {
+				return ran, fmt.Errorf("migration %d %s failed: %v", migration.Version, migration.Name, err)
+			}
+		}
+
+		err := session.Query("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			migration.Version, migration.Name, time.Now()).WithContext(ctx).Exec()
+		if err != nil {
+			return ran, fmt.Errorf("failed to record migration %d: %v", migration.Version, err)
+		}
+
+		logger.Info("applied migration", "version", migration.Version, "name", migration.Name)
+		ran = append(ran, migration)
+	}
+
+	return ran, nil
+}
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLoadMigrations tests the embedded migrations are ordered and split
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrations)
+
+	for i, migration := range migrations {
+		assert.Equal(t, i+1, migration.Version)
+		assert.NotEmpty(t, migration.Statements, migration.Name)
+		for _, statement := range migration.Statements {
+			assert.NotContains(t, statement, ";")
+			assert.NotContains(t, statement, "--")
+		}
+	}
+
+	assert.Len(t, migrations[0].Statements, 5)
+}
@@ -19,13 +19,36 @@ type Campaign struct {
 
 // List represents a list record from cassandra
 type List struct {
-	ListNumber  string     `cql:"listnumber"`
-	CampaignID  string     `cql:"campaignid"`
-	WorkspaceID string     `cql:"workspace_id"`
-	ListName    string     `cql:"listname"`
-	Active      bool       `cql:"active"`
-	CreatedAt   *time.Time `cql:"createdat"`
-	UpdatedAt   *time.Time `cql:"updatedat"`
+	ListNumber     string     `cql:"listnumber"`
+	CampaignID     string     `cql:"campaignid"`
+	WorkspaceID    string     `cql:"workspace_id"`
+	ListName       string     `cql:"listname"`
+	Active         bool       `cql:"active"`
+	Weight         int        `cql:"weight"`
+	Priority       int        `cql:"priority"`
+	MaxCallsPerDay int        `cql:"max_calls_per_day"`
+	ActiveFrom     *time.Time `cql:"active_from"`
+	ActiveUntil    *time.Time `cql:"active_until"`
+	CreatedAt      *time.Time `cql:"createdat"`
+	UpdatedAt      *time.Time `cql:"updatedat"`
+}
+
+// IsActiveAt reports whether the list is active and t falls inside its
+// optional active date range
+func (l List) IsActiveAt(t time.Time) bool {
+	if !l.Active {
+		return false
+	}
+
+	if l.ActiveFrom != nil && t.Before(*l.ActiveFrom) {
+		return false
+	}
+
+	if l.ActiveUntil != nil && t.After(*l.ActiveUntil) {
+		return false
+	}
+
+	return true
 }
 
 // ListData represents a Lead record from cassandra
@@ -44,3 +67,27 @@ type ListData struct {
 	LastCallDate *time.Time        `cql:"lastcalldate"`
 	CallStatus   string            `cql:"callstatus"`
 }
+
+// Callback represents a scheduled callback record from cassandra. Callbacks are
+// partitioned by workspace and clustered by due time so the hopper can read
+// the due ones with a single range query.
+type Callback struct {
+	ID          string     `cql:"callback_id"`
+	WorkspaceID string     `cql:"workspace_id"`
+	CampaignID  string     `cql:"campaign_id"`
+	ListNumber  string     `cql:"listnumber"`
+	LeadID      string     `cql:"leadid"`
+	AgentID     string     `cql:"agent_id"`
+	DueAt       time.Time  `cql:"due_at"`
+	Status      string     `cql:"status"`
+	CreatedAt   *time.Time `cql:"createdat"`
+}
+
+const (
+	// CallbackPending is a callback waiting for its due time
+	CallbackPending = "pending"
+	// CallbackQueued is a callback whose lead has been pushed to the workspace queue
+	CallbackQueued = "queued"
+	// CallbackCancelled is a callback that will not be dialed
+	CallbackCancelled = "cancelled"
+)
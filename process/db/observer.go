@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nico-phil/process/metrics"
+	"github.com/nico-phil/process/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("db")
+
+// queryObserver records the latency and errors of every query and batch, and
+// a span for the ones run with a traced context
+type queryObserver struct{}
+
+// ObserveQuery implements gocql.QueryObserver
+func (queryObserver) ObserveQuery(ctx context.Context, q gocql.ObservedQuery) {
+	operation := statementOperation(q.Statement)
+	observe(operation, q.Start, q.End, q.Err)
+
+	traceQuery(ctx, operation, q.Start, q.End, q.Err, q.Host, queryAttributes(q)...)
+}
+
+// queryAttributes returns the span attributes of a query. The bound values
+// are left out, they hold lead data such as phone numbers.
+func queryAttributes(q gocql.ObservedQuery) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("db.namespace", q.Keyspace),
+		attribute.String("db.query.text", q.Statement),
+		attribute.Int("db.response.returned_rows", q.Rows),
+		attribute.Int("db.cassandra.attempt", q.Attempt),
+	}
+}
+
+// ObserveBatch implements gocql.BatchObserver
+func (queryObserver) ObserveBatch(ctx context.Context, b gocql.ObservedBatch) {
+	observe("batch", b.Start, b.End, b.Err)
+	traceQuery(ctx, "batch", b.Start, b.End, b.Err, b.Host,
+		attribute.String("db.namespace", b.Keyspace),
+		attribute.StringSlice("db.query.text", b.Statements),
+		attribute.Int("db.operation.batch.size", len(b.Statements)),
+	)
+}
+
+// traceQuery records a query that has already run as a child span of ctx
+func traceQuery(ctx context.Context, operation string, start, end time.Time, err error, host *gocql.HostInfo, attrs ...attribute.KeyValue) {
+	if !tracing.Traced(ctx) {
+		return
+	}
+
+	attrs = append(attrs, attribute.String("db.system.name", "cassandra"), attribute.String("db.operation.name", operation))
+	if host != nil {
+		attrs = append(attrs, attribute.String("server.address", host.ConnectAddress().String()), attribute.String("db.cassandra.dc", host.DataCenter()))
+	}
+
+	_, span := tracer.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+	if err != nil && err != gocql.ErrNotFound {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
+
+func observe(operation string, start, end time.Time, err error) {
+	metrics.CassandraDuration.WithLabelValues(operation).Observe(end.Sub(start).Seconds())
+	if err != nil && err != gocql.ErrNotFound {
+		metrics.CassandraErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// statementOperation labels a statement with its verb and table, e.g.
+// "select leads"
+func statementOperation(statement string) string {
+	fields := strings.Fields(strings.ToLower(statement))
+	if len(fields) == 0 {
+		return "unknown"
+	}
+
+	verb := fields[0]
+	for i, field := range fields[:len(fields)-1] {
+		if field == "from" || field == "into" || (verb == "update" && i == 0) {
+			table := strings.TrimRight(fields[i+1], "(;")
+			if j := strings.IndexByte(table, '('); j >= 0 {
+				table = table[:j]
+			}
+			return verb + " " + table
+		}
+	}
+
+	return verb
+}
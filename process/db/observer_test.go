@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestStatementOperation tests statements are labeled with their verb and table
+func TestStatementOperation(t *testing.T) {
+	cases := map[string]string{
+		"SELECT id, name FROM campaigns WHERE workspace_id = ?":  "select campaigns",
+		"INSERT INTO callbacks(workspace_id, due_at) VALUES (?)": "insert callbacks",
+		"UPDATE list_data SET dialable = ? WHERE lead_id = ?":    "update list_data",
+		"DELETE FROM callbacks WHERE callback_id = ?":            "delete callbacks",
+		"": "unknown",
+	}
+
+	for statement, expected := range cases {
+		assert.Equal(t, expected, statementOperation(statement), statement)
+	}
+}
+
+// TestTraceQuery tests queries become child spans of a traced context only
+func TestTraceQuery(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	start := time.Now().Add(-time.Second)
+	end := start.Add(300 * time.Millisecond)
+
+	// no span in the context, nothing is recorded
+	traceQuery(context.Background(), "select leads", start, end, nil, nil)
+	assert.Empty(t, recorder.Ended())
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "hopper.cycle")
+	traceQuery(ctx, "select leads", start, end, errors.New("timeout"), nil, attribute.String("db.namespace", "ks"))
+	parent.End()
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+
+	query := spans[0]
+	assert.Equal(t, "select leads", query.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), query.Parent().SpanID())
+	assert.Equal(t, start, query.StartTime())
+	assert.Equal(t, end, query.EndTime())
+	assert.Equal(t, codes.Error, query.Status().Code)
+	assert.Contains(t, query.Attributes(), attribute.String("db.system.name", "cassandra"))
+}
+
+// TestQueryAttributesOmitValues tests the bound values of a query, which
+// hold lead data, are never recorded on its span
+func TestQueryAttributesOmitValues(t *testing.T) {
+	attrs := queryAttributes(gocql.ObservedQuery{
+		Keyspace:  "ks",
+		Statement: "SELECT * FROM list_data WHERE phonenumber = ?",
+		Values:    []interface{}{"5550100"},
+	})
+
+	assert.Contains(t, attrs, attribute.String("db.namespace", "ks"))
+	for _, attr := range attrs {
+		assert.NotEqual(t, "db.cassandra.values", string(attr.Key))
+		assert.NotContains(t, attr.Value.Emit(), "5550100")
+	}
+}
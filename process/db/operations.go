@@ -3,19 +3,21 @@ package db
 import (
 	"context"
 	"fmt"
-	"log"
 	"slices"
 	"time"
+
+	"github.com/nico-phil/process/logging"
 )
 
 // GetCampaigns retrive all campaign from the database
-func GetAllCampaigns() ([]Campaign, error) {
+func GetAllCampaigns(ctx context.Context) ([]Campaign, error) {
+	session := Getsession()
 	if session == nil {
 		return []Campaign{}, ErrNoConnection
 	}
 	query := `SELECT id, workspace_id, name, description, active, max_rate_per_min, dial_start_hour, dial_end_hour, dial_days, createdat, modifiedat FROM campaigns`
 
-	scanner := session.Query(query).Iter().Scanner()
+	scanner := session.Query(query).WithContext(ctx).Iter().Scanner()
 
 	campaigns := []Campaign{}
 
@@ -36,7 +38,7 @@ func GetAllCampaigns() ([]Campaign, error) {
 		)
 
 		if err != nil {
-			log.Printf("error reading campaigns: %v", err)
+			logger.Error("failed to read campaign", "error", err)
 			return []Campaign{}, fmt.Errorf("db: error reading campaigns %v", err)
 		}
 
@@ -47,12 +49,13 @@ func GetAllCampaigns() ([]Campaign, error) {
 		return []Campaign{}, fmt.Errorf("db: error reading campaigns %v", err)
 	}
 
-	log.Printf("retrieved %d campaigns", len(campaigns))
+	logger.Debug("retrieved campaigns", "count", len(campaigns))
 	return campaigns, nil
 }
 
 // GetLists retrives all lists fro the database
 func GetAllLists() ([]List, error) {
+	session := Getsession()
 	if session == nil {
 		return []List{}, ErrNoConnection
 	}
@@ -74,7 +77,7 @@ func GetAllLists() ([]List, error) {
 		)
 
 		if err != nil {
-			log.Printf("error reading list: %v", err)
+			logger.Error("failed to read list", "error", err)
 			return nil, err
 		}
 
@@ -82,20 +85,21 @@ func GetAllLists() ([]List, error) {
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Printf("error reading list: %v", err)
+		logger.Error("failed to read list", "error", err)
 		return nil, err
 	}
 
-	log.Printf("retrieved %d lists", len(lists))
+	logger.Debug("retrieved lists", "count", len(lists))
 	return lists, nil
 }
 
 // GetActiveListByCampaign retrives all active lists for a spcecific campaign from the database
 func GetActiveListByCampaign(ctx context.Context, campaignID string) ([]List, error) {
+	session := Getsession()
 	if session == nil {
 		return nil, ErrNoConnection
 	}
-	query := `SELECT listnumber, listname, workspace_id, campaignid, active, createdat, updatedat from lists where campaignid=? AND active=true ALLOW FILTERING`
+	query := `SELECT listnumber, listname, workspace_id, campaignid, active, weight, priority, max_calls_per_day, active_from, active_until, createdat, updatedat from lists where campaignid=? AND active=true ALLOW FILTERING`
 
 	var lists []List
 	scanner := session.Query(query, campaignID).WithContext(ctx).Iter().Scanner()
@@ -107,6 +111,11 @@ func GetActiveListByCampaign(ctx context.Context, campaignID string) ([]List, er
 			&list.WorkspaceID,
 			&list.CampaignID,
 			&list.Active,
+			&list.Weight,
+			&list.Priority,
+			&list.MaxCallsPerDay,
+			&list.ActiveFrom,
+			&list.ActiveUntil,
 			&list.CreatedAt,
 			&list.UpdatedAt,
 		)
@@ -125,6 +134,7 @@ func GetActiveListByCampaign(ctx context.Context, campaignID string) ([]List, er
 }
 
 func GetCampaignsByWorkspace(workspaceID string) ([]Campaign, error) {
+	session := Getsession()
 	if session == nil {
 		return []Campaign{}, ErrNoConnection
 	}
@@ -151,7 +161,7 @@ func GetCampaignsByWorkspace(workspaceID string) ([]Campaign, error) {
 		)
 
 		if err != nil {
-			log.Printf("error reading campaigns for workspace %s: %v", workspaceID, err)
+			logger.Error("failed to read campaigns", logging.WorkspaceID, workspaceID, "error", err)
 			return []Campaign{}, fmt.Errorf("db: error reading campaigns for workspace %s: %v", workspaceID, err)
 		}
 
@@ -162,27 +172,28 @@ func GetCampaignsByWorkspace(workspaceID string) ([]Campaign, error) {
 		return []Campaign{}, err
 	}
 
-	log.Printf("db: retrieved %d for workspace %s", len(campaigns), workspaceID)
+	logger.Debug("retrieved campaigns", logging.WorkspaceID, workspaceID, "count", len(campaigns))
 
 	return campaigns, nil
 
 }
 
 // GetLeadsCount counts leads for list (listnumber -> count)
-func GetLeadsCount(worksapceID string) (map[string]int, error) {
+func GetLeadsCount(ctx context.Context, worksapceID string) (map[string]int, error) {
+	session := Getsession()
 	if session == nil {
 		return nil, ErrNoConnection
 	}
 
 	query := `SELECT listnumber FROM list_data where workspace_id=? `
-	scanner := session.Query(query, worksapceID).Iter().Scanner()
+	scanner := session.Query(query, worksapceID).WithContext(ctx).Iter().Scanner()
 
 	var listnumbers []string
 	for scanner.Next() {
 		var listnumber string
 		err := scanner.Scan(&listnumber)
 		if err != nil {
-			log.Printf("error reading listnumber for workspace: %s", worksapceID)
+			logger.Error("failed to read list number", logging.WorkspaceID, worksapceID, "error", err)
 			return nil, fmt.Errorf("error reading listnumber for workspace: %s", worksapceID)
 		}
 
@@ -190,7 +201,7 @@ func GetLeadsCount(worksapceID string) (map[string]int, error) {
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Printf("error reading listnumber for workspace: %s", worksapceID)
+		logger.Error("failed to read list number", logging.WorkspaceID, worksapceID, "error", err)
 		return nil, fmt.Errorf("error reading listnumber for workspace: %s", worksapceID)
 
 	}
@@ -205,6 +216,7 @@ func GetLeadsCount(worksapceID string) (map[string]int, error) {
 
 // GetListsByWorkspace retreive lists for a single workspace
 func GetListsByWorkspace(workspaceID string) ([]List, error) {
+	session := Getsession()
 	if session == nil {
 		return nil, ErrNoConnection
 	}
@@ -224,7 +236,7 @@ func GetListsByWorkspace(workspaceID string) ([]List, error) {
 			&list.UpdatedAt,
 		)
 		if err != nil {
-			log.Printf("db: error reading lists for workspace: %v", err)
+			logger.Error("failed to read list", logging.WorkspaceID, workspaceID, "error", err)
 			return nil, fmt.Errorf("db: failed to get lists for workspace: %s : %w", workspaceID, err)
 		}
 
@@ -235,12 +247,13 @@ func GetListsByWorkspace(workspaceID string) ([]List, error) {
 		return nil, fmt.Errorf("db: failed to close iterator: %s : %w", workspaceID, err)
 	}
 
-	log.Printf("db: retrieved %d list for workspace %s", len(lists), workspaceID)
+	logger.Debug("retrieved lists", logging.WorkspaceID, workspaceID, "count", len(lists))
 	return lists, nil
 }
 
 // GetActiveCampaignsWithSchedule retrives active campaign between a time window
 func GetActiveCampaignsWithSchedule(workspaceID string, currentTime time.Time) ([]Campaign, error) {
+	session := Getsession()
 	if session == nil {
 		return []Campaign{}, ErrNoConnection
 	}
@@ -268,7 +281,7 @@ func GetActiveCampaignsWithSchedule(workspaceID string, currentTime time.Time) (
 		)
 
 		if err != nil {
-			log.Printf("error reading campaigns for workspace %s: %v", workspaceID, err)
+			logger.Error("failed to read campaigns", logging.WorkspaceID, workspaceID, "error", err)
 			return []Campaign{}, fmt.Errorf("db: error reading campaigns for workspace %s: %v", workspaceID, err)
 		}
 
@@ -282,18 +295,19 @@ func GetActiveCampaignsWithSchedule(workspaceID string, currentTime time.Time) (
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Printf("error reading campaigns for workspace %s: %v", workspaceID, err)
+		logger.Error("failed to read campaigns", logging.WorkspaceID, workspaceID, "error", err)
 		return []Campaign{}, fmt.Errorf("db: error reading campaigns for workspace %s: %v", workspaceID, err)
 	}
 
-	log.Printf("db: retrieved %d for workspace %s", len(campaigns), workspaceID)
+	logger.Debug("retrieved campaigns", logging.WorkspaceID, workspaceID, "count", len(campaigns))
 
 	return campaigns, nil
 
 }
 
-func GetDialableLeads(workspaceID, listNumber string, limit int) ([]ListData, error) {
+func GetDialableLeads(ctx context.Context, workspaceID, listNumber string, limit int) ([]ListData, error) {
 
+	session := Getsession()
 	if session == nil {
 		return []ListData{}, ErrNoConnection
 	}
@@ -301,7 +315,7 @@ func GetDialableLeads(workspaceID, listNumber string, limit int) ([]ListData, er
 	// add limit
 	query := "SELECT leadid, listnumber, workspace_id, phonenumber, firstname, lastname, zipcode, extradata, callcount, dialable, inserteddate, lastcalldate, callstatus FROM list_data WHERE workspace_id = ? AND listnumber = ? AND dialable = true LIMIT ? ALLOW FILTERING"
 
-	scanner := session.Query(query, workspaceID, listNumber, limit).Iter().Scanner()
+	scanner := session.Query(query, workspaceID, listNumber, limit).WithContext(ctx).Iter().Scanner()
 
 	var leads []ListData
 	for scanner.Next() {
@@ -323,19 +337,20 @@ func GetDialableLeads(workspaceID, listNumber string, limit int) ([]ListData, er
 		)
 
 		if err != nil {
-			log.Printf("db: error reading dialable leads fro workspace %s, list %s: %v", workspaceID, listNumber, err)
+			logger.Error("failed to read dialable lead", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, "error", err)
 			return []ListData{}, fmt.Errorf("db: error reading dialable leads fro workspace %s, list %s: %v", workspaceID, listNumber, err)
 		}
 
 		leads = append(leads, lead)
 	}
 
-	log.Printf("retrieved %d leads for workspace %s, list %s", len(leads), workspaceID, listNumber)
+	logger.Debug("retrieved dialable leads", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, "count", len(leads))
 	return leads, nil
 }
 
 // UpdateLeadDialStatus updates lead dialable status and call count
-func UpdateLeadDialStatus(workspaceID, listNumber, leadID string, dialable bool) error {
+func UpdateLeadDialStatus(ctx context.Context, workspaceID, listNumber, leadID string, dialable bool) error {
+	session := Getsession()
 	if session == nil {
 		return ErrNoConnection
 	}
@@ -345,41 +360,42 @@ func UpdateLeadDialStatus(workspaceID, listNumber, leadID string, dialable bool)
 
 	var count int
 	query = "select callcount from list_data where workspace_id= ? AND listnumber= ? AND leadid= ?"
-	if err := session.Query(query, workspaceID, listNumber, leadID).Scan(&count); err != nil {
-		log.Printf("Error getting callcount: %v", err)
+	if err := session.Query(query, workspaceID, listNumber, leadID).WithContext(ctx).Scan(&count); err != nil {
+		logger.Error("failed to get call count", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, logging.LeadID, leadID, "error", err)
 		return err
 	}
 
 	if dialable {
 		// Setting back to dialable, decrement call count
 		query = "UPDATE list_data SET dialable = ?, callcount = ? WHERE workspace_id = ? AND listnumber = ? AND leadid = ?"
-		if err := session.Query(query, dialable, count-1, workspaceID, listNumber, leadID).Exec(); err != nil {
-			log.Printf("Error updating lead status: %v", err)
+		if err := session.Query(query, dialable, count-1, workspaceID, listNumber, leadID).WithContext(ctx).Exec(); err != nil {
+			logger.Error("failed to update lead dial status", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, logging.LeadID, leadID, "error", err)
 			return err
 		}
 
 	} else {
 		// Setting to non-dialable, increment call count and update last call date
 		query = "UPDATE list_data SET dialable = ?, callcount = ?, lastcalldate = ? WHERE workspace_id = ? AND listnumber = ? AND leadid = ?"
-		if err := session.Query(query, dialable, count+1, now, workspaceID, listNumber, leadID).Exec(); err != nil {
-			log.Printf("Error updating lead status: %v", err)
+		if err := session.Query(query, dialable, count+1, now, workspaceID, listNumber, leadID).WithContext(ctx).Exec(); err != nil {
+			logger.Error("failed to update lead dial status", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, logging.LeadID, leadID, "error", err)
 			return err
 		}
 		return nil
 	}
 
-	log.Printf("Updated lead %s dialable status to %v", leadID, dialable)
+	logger.Debug("updated lead dial status", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, logging.LeadID, leadID, "dialable", dialable)
 	return nil
 }
 
 // GetLeadCounts returns count of dialable leads per list for a workspace
-func GetLeadCounts(workspaceID string) (map[string]int, error) {
+func GetLeadCounts(ctx context.Context, workspaceID string) (map[string]int, error) {
+	session := Getsession()
 	if session == nil {
 		return nil, ErrNoConnection
 	}
 
 	query := "SELECT listnumber FROM list_data WHERE workspace_id = ? AND dialable = true  ALLOW FILTERING"
-	iter := session.Query(query, workspaceID).Iter()
+	iter := session.Query(query, workspaceID).WithContext(ctx).Iter()
 
 	counts := make(map[string]int)
 	var listNumbers []string
@@ -396,24 +412,89 @@ func GetLeadCounts(workspaceID string) (map[string]int, error) {
 	}
 
 	if err := iter.Close(); err != nil {
-		log.Printf("Error reading lead counts for workspace %s: %v", workspaceID, err)
+		logger.Error("failed to read lead counts", logging.WorkspaceID, workspaceID, "error", err)
 		return nil, err
 	}
 
-	log.Printf("Retrieved lead counts for %d lists in workspace %s", len(counts), workspaceID)
+	logger.Debug("retrieved lead counts", logging.WorkspaceID, workspaceID, "lists", len(counts))
 	return counts, nil
 
 }
 
-// TODO: Add GetNonDialableLeadIDs function for complete cleanup of deactivated lists
-// This function should return all lead IDs that are marked as non-dialable for a specific list
-// func GetNonDialableLeadIDs(workspaceID, listNumber string) ([]string, error) {
-//     query := "SELECT leadid FROM list_data WHERE workspace_id = ? AND listnumber = ? AND dialable = false ALLOW FILTERING"
-//     // Implementation needed for cleanup/service.go to reset ALL non-dialable leads in deactivated lists
-// }
+// GetNonDialableLeadIDs returns all lead IDs that are marked as non-dialable for a specific list
+func GetNonDialableLeadIDs(workspaceID, listNumber string) ([]string, error) {
+	session := Getsession()
+	if session == nil {
+		return nil, ErrNoConnection
+	}
+
+	query := "SELECT leadid FROM list_data WHERE workspace_id = ? AND listnumber = ? AND dialable = false ALLOW FILTERING"
+	scanner := session.Query(query, workspaceID, listNumber).Iter().Scanner()
+
+	var leadIDs []string
+	for scanner.Next() {
+		var leadID string
+		if err := scanner.Scan(&leadID); err != nil {
+			logger.Error("failed to read non dialable lead", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, "error", err)
+			return nil, fmt.Errorf("db: error reading non dialable leads for workspace %s, list %s: %w", workspaceID, listNumber, err)
+		}
+
+		leadIDs = append(leadIDs, leadID)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("db: failed to close iterator: %s, %s : %w", workspaceID, listNumber, err)
+	}
+
+	logger.Debug("retrieved non dialable leads", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, "count", len(leadIDs))
+	return leadIDs, nil
+}
+
+// GetUndispositionedLeadIDs returns the non-dialable leads of a list taken before a
+// given time and not dispositioned since, the leads an interrupted injection can
+// strand. Taking a lead stamps lastcalldate, a disposition writes callstatus, so a
+// lead whose call status was written before it was last taken, e.g. a lead dialed
+// again after an earlier call, is undispositioned.
+func GetUndispositionedLeadIDs(workspaceID, listNumber string, takenBefore time.Time) ([]string, error) {
+	session := Getsession()
+	if session == nil {
+		return nil, ErrNoConnection
+	}
+
+	query := "SELECT leadid, lastcalldate, WRITETIME(callstatus) FROM list_data WHERE workspace_id = ? AND listnumber = ? AND dialable = false ALLOW FILTERING"
+	scanner := session.Query(query, workspaceID, listNumber).Iter().Scanner()
+
+	var leadIDs []string
+	for scanner.Next() {
+		var leadID string
+		var lastCallDate *time.Time
+		var statusWrittenAt *int64
+		if err := scanner.Scan(&leadID, &lastCallDate, &statusWrittenAt); err != nil {
+			logger.Error("failed to read undispositioned lead", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, "error", err)
+			return nil, fmt.Errorf("db: error reading undispositioned leads for workspace %s, list %s: %w", workspaceID, listNumber, err)
+		}
+
+		if statusWrittenAt != nil && (lastCallDate == nil || !time.UnixMicro(*statusWrittenAt).Before(*lastCallDate)) {
+			continue
+		}
+
+		if lastCallDate != nil && lastCallDate.After(takenBefore) {
+			continue
+		}
+
+		leadIDs = append(leadIDs, leadID)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("db: failed to close iterator: %s, %s : %w", workspaceID, listNumber, err)
+	}
+
+	return leadIDs, nil
+}
 
 // GetActiveListsByCampaign retrieves active lists for a specific campaign
 func GetActiveListsByCampaign(campaignID string) ([]List, error) {
+	session := Getsession()
 	if session == nil {
 		return nil, ErrNoConnection
 	}
@@ -431,49 +512,48 @@ func GetActiveListsByCampaign(campaignID string) ([]List, error) {
 	}
 
 	if err := iter.Close(); err != nil {
-		log.Printf("Error reading active lists for campaign %s: %v", campaignID, err)
+		logger.Error("failed to read active lists", logging.CampaignID, campaignID, "error", err)
 		return nil, err
 	}
 
-	log.Printf("Retrieved %d active lists for campaign %s", len(lists), campaignID)
+	logger.Debug("retrieved active lists", logging.CampaignID, campaignID, "count", len(lists))
 	return lists, nil
 }
 
 // BatchUpdateLeadsDialable updates multiple leads' dialable status
 func BatchUpdateLeadsDialable(workspaceID string, listNumber string, leadIDs []string, dialable bool) error {
-	if session == nil {
+	if Getsession() == nil {
 		return ErrNoConnection
 	}
 
-	now := time.Now()
-	var query string
-
-	if dialable {
-		query = "UPDATE list_data SET dialable = ?, callcount = callcount - 1 WHERE workspace_id = ? AND listnumber = ? AND leadid = ?"
-	} else {
-		query = "UPDATE list_data SET dialable = ?, callcount = callcount + 1, lastcalldate = ? WHERE workspace_id = ? AND listnumber = ? AND leadid = ?"
-	}
-
+	// callcount is a plain int, each lead is read and written like a single update
 	for _, leadID := range leadIDs {
-		var err error
-		if dialable {
-			err = session.Query(query, dialable, workspaceID, listNumber, leadID).Exec()
-		} else {
-			err = session.Query(query, dialable, now, workspaceID, listNumber, leadID).Exec()
-		}
-
-		if err != nil {
-			log.Printf("Error updating lead %s status: %v", leadID, err)
+		if err := UpdateLeadDialStatus(context.Background(), workspaceID, listNumber, leadID, dialable); err != nil {
 			return err
 		}
 	}
 
-	log.Printf("Batch updated %d leads dialable status to %v", len(leadIDs), dialable)
+	logger.Debug("batch updated lead dial status", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, "count", len(leadIDs), "dialable", dialable)
 	return nil
 }
 
+// LeadStatusBadPhone is the call status of a lead whose phone number cannot
+// be dialed. The lead stays taken, reconciliation sees it dispositioned.
+const LeadStatusBadPhone = "bad_phone"
+
+// LeadStatusDeadLetterDropped is the call status of a lead dropped from a
+// full dead letter queue. The lead stays taken like a bad phone lead.
+const LeadStatusDeadLetterDropped = "dead_letter_dropped"
+
+// IsTerminalLeadStatus reports whether a call status ends the life of a lead,
+// it is never queued again
+func IsTerminalLeadStatus(status string) bool {
+	return status == LeadStatusBadPhone || status == LeadStatusDeadLetterDropped
+}
+
 // UpdateLeadStatus updates lead status and related fields
 func UpdateLeadStatus(workspaceID, listNumber, leadID, status string) error {
+	session := Getsession()
 	if session == nil {
 		return ErrNoConnection
 	}
@@ -482,16 +562,17 @@ func UpdateLeadStatus(workspaceID, listNumber, leadID, status string) error {
 	query := "UPDATE list_data SET callstatus = ?, lastcalldate = ? WHERE workspace_id = ? AND listnumber = ? AND leadid = ?"
 
 	if err := session.Query(query, status, now, workspaceID, listNumber, leadID).Exec(); err != nil {
-		log.Printf("[%s]: Error updating lead status: %v", workspaceID, err)
+		logger.Error("failed to update lead status", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, logging.LeadID, leadID, "error", err)
 		return err
 	}
 
-	log.Printf("[%s]: Updated lead %s status to %s", workspaceID, leadID, status)
+	logger.Debug("updated lead status", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, logging.LeadID, leadID, "status", status)
 	return nil
 }
 
 // GetLeadByID retrieves a specific lead by ID
-func GetLeadByID(workspaceID, listNumber, leadID string) (*ListData, error) {
+func GetLeadByID(ctx context.Context, workspaceID, listNumber, leadID string) (*ListData, error) {
+	session := Getsession()
 	if session == nil {
 		return nil, ErrNoConnection
 	}
@@ -499,15 +580,39 @@ func GetLeadByID(workspaceID, listNumber, leadID string) (*ListData, error) {
 	query := "SELECT leadid, listnumber, workspace_id, phonenumber, firstname, lastname, zipcode, extradata, callcount, dialable, inserteddate, lastcalldate, callstatus FROM list_data WHERE workspace_id = ? AND listnumber = ? AND leadid = ?"
 
 	var lead ListData
-	if err := session.Query(query, workspaceID, listNumber, leadID).Scan(
+	if err := session.Query(query, workspaceID, listNumber, leadID).WithContext(ctx).Scan(
 		&lead.LeadID, &lead.ListNumber, &lead.WorkspaceID, &lead.PhoneNumber,
 		&lead.FirstName, &lead.LastName, &lead.ZipCode, &lead.ExtraData,
 		&lead.CallCount, &lead.Dialable, &lead.InsertedDate,
 		&lead.LastCallDate, &lead.CallStatus); err != nil {
-		log.Printf("Error reading lead %s: %v", leadID, err)
+		logger.Error("failed to read lead", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, logging.LeadID, leadID, "error", err)
 		return nil, err
 	}
 
-	log.Printf("Retrieved lead %s", leadID)
+	logger.Debug("retrieved lead", logging.WorkspaceID, workspaceID, logging.ListNumber, listNumber, logging.LeadID, leadID)
 	return &lead, nil
 }
+
+// SetCampaignActive activates or pauses a campaign. ErrCampaignNotFound is
+// returned when the workspace has no such campaign.
+func SetCampaignActive(ctx context.Context, workspaceID, campaignID string, active bool) error {
+	session := Getsession()
+	if session == nil {
+		return ErrNoConnection
+	}
+
+	// IF EXISTS keeps an unknown campaign from being created by the update
+	query := "UPDATE campaigns SET active = ?, modifiedat = ? WHERE workspace_id = ? AND id = ? IF EXISTS"
+	applied, err := session.Query(query, active, time.Now(), workspaceID, campaignID).WithContext(ctx).ScanCAS()
+	if err != nil {
+		logger.Error("failed to update campaign state", logging.WorkspaceID, workspaceID, logging.CampaignID, campaignID, "error", err)
+		return fmt.Errorf("db: failed to update campaign %s: %w", campaignID, err)
+	}
+
+	if !applied {
+		return ErrCampaignNotFound
+	}
+
+	logger.Debug("updated campaign state", logging.WorkspaceID, workspaceID, logging.CampaignID, campaignID, "active", active)
+	return nil
+}
@@ -1,7 +1,9 @@
 package db
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -10,26 +12,69 @@ import (
 func TestGetCampaigns_NoConnection(t *testing.T) {
 
 	// save original session
-	originalsession := session
+	originalsession := current.Load()
 	defer func() {
-		session = originalsession
+		current.Store(originalsession)
 	}()
 
 	// set the session to nil, since we are connected to db
-	session = nil
-	_, err := GetAllCampaigns()
-	assert.Nil(t, session)
+	current.Store(nil)
+	_, err := GetAllCampaigns(context.Background())
+	assert.Nil(t, Getsession())
 	assert.Equal(t, ErrNoConnection, err)
 }
 
 func TestGetLists_NoConnection(t *testing.T) {
-	originalsession := session
+	originalsession := current.Load()
 	defer func() {
-		session = originalsession
+		current.Store(originalsession)
 	}()
 
-	session = nil
+	current.Store(nil)
 	_, err := GetAllLists()
-	assert.Nil(t, session)
+	assert.Nil(t, Getsession())
 	assert.Equal(t, ErrNoConnection, err)
 }
+
+func TestGetNonDialableLeadIDs_NoConnection(t *testing.T) {
+	originalsession := current.Load()
+	defer func() {
+		current.Store(originalsession)
+	}()
+
+	current.Store(nil)
+	_, err := GetNonDialableLeadIDs("workspace-1", "1001")
+	assert.Nil(t, Getsession())
+	assert.Equal(t, ErrNoConnection, err)
+}
+
+func TestGetUndispositionedLeadIDs_NoConnection(t *testing.T) {
+	originalsession := current.Load()
+	defer func() {
+		current.Store(originalsession)
+	}()
+
+	current.Store(nil)
+	_, err := GetUndispositionedLeadIDs("workspace-1", "1001", time.Now())
+	assert.Nil(t, Getsession())
+	assert.Equal(t, ErrNoConnection, err)
+}
+
+func TestCreateCallback_NoConnection(t *testing.T) {
+	originalsession := current.Load()
+	defer func() {
+		current.Store(originalsession)
+	}()
+
+	current.Store(nil)
+	_, err := CreateCallback(Callback{WorkspaceID: "workspace-1", LeadID: "lead-1"})
+	assert.Nil(t, Getsession())
+	assert.Equal(t, ErrNoConnection, err)
+}
+
+func TestIsTerminalLeadStatus(t *testing.T) {
+	assert.True(t, IsTerminalLeadStatus(LeadStatusBadPhone))
+	assert.True(t, IsTerminalLeadStatus(LeadStatusDeadLetterDropped))
+	assert.False(t, IsTerminalLeadStatus(""))
+	assert.False(t, IsTerminalLeadStatus("no_answer"))
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaTestKeyspace is the throwaway keyspace of the tests run against a
+// cassandra node
+const schemaTestKeyspace = "process_schema_test"
+
+// baseTables are the tables of the keyspace this process does not own, as
+// it reads and writes them. Migrations are applied on top of them.
+var baseTables = []string{
+	`CREATE TABLE IF NOT EXISTS campaigns (
+		workspace_id text, id text, name text, description text, active boolean,
+		max_rate_per_min int, dial_start_hour int, dial_end_hour int, dial_days list<int>,
+		createdat timestamp, modifiedat timestamp,
+		PRIMARY KEY ((workspace_id), id))`,
+	`CREATE TABLE IF NOT EXISTS lists (
+		workspace_id text, listnumber text, campaignid text, listname text, active boolean,
+		createdat timestamp, updatedat timestamp,
+		PRIMARY KEY ((workspace_id), listnumber))`,
+	`CREATE TABLE IF NOT EXISTS list_data (
+		workspace_id text, listnumber text, leadid text, phonenumber text, firstname text,
+		lastname text, zipcode text, extradata map<text, text>, callcount int, dialable boolean,
+		inserteddate timestamp, lastcalldate timestamp, callstatus text,
+		PRIMARY KEY ((workspace_id, listnumber), leadid))`,
+}
+
+// schemaSession connects to the cassandra node of CASSANDRA_TEST_CONTACT_POINTS,
+// creates a fresh keyspace with the base tables and the migrations, and makes
+// it the current session until the test ends. The test is skipped without a node.
+func schemaSession(t *testing.T) *gocql.Session {
+	t.Helper()
+
+	contactPoints := os.Getenv("CASSANDRA_TEST_CONTACT_POINTS")
+	if contactPoints == "" {
+		t.Skip("CASSANDRA_TEST_CONTACT_POINTS is not set")
+	}
+
+	cluster := gocql.NewCluster(strings.Fields(contactPoints)...)
+	cluster.Timeout = 30 * time.Second
+	admin, err := cluster.CreateSession()
+	require.NoError(t, err)
+	defer admin.Close()
+
+	require.NoError(t, admin.Query("DROP KEYSPACE IF EXISTS "+schemaTestKeyspace).Exec())
+	require.NoError(t, admin.Query("CREATE KEYSPACE "+schemaTestKeyspace+" WITH replication = {'class': 'SimpleStrategy', 'replication_factor': 1}").Exec())
+
+	cluster.Keyspace = schemaTestKeyspace
+	session, err := cluster.CreateSession()
+	require.NoError(t, err)
+
+	for _, table := range baseTables {
+		require.NoError(t, session.Query(table).Exec())
+	}
+
+	original := current.Swap(session)
+	t.Cleanup(func() {
+		current.Store(original)
+		session.Close()
+	})
+
+	_, err = Migrate(context.Background())
+	require.NoError(t, err)
+
+	return session
+}
+
+// TestBatchUpdateLeadsDialableSchema tests leads are taken and given back
+// against the real list_data table, callcount following each change
+func TestBatchUpdateLeadsDialableSchema(t *testing.T) {
+	session := schemaSession(t)
+
+	insert := "INSERT INTO list_data (workspace_id, listnumber, leadid, phonenumber, callcount, dialable) VALUES (?, ?, ?, ?, ?, ?)"
+	require.NoError(t, session.Query(insert, "ws1", "l1", "lead1", "5550100", 2, true).Exec())
+	require.NoError(t, session.Query(insert, "ws1", "l1", "lead2", "5550101", 0, true).Exec())
+
+	leadIDs := []string{"lead1", "lead2"}
+	require.NoError(t, BatchUpdateLeadsDialable("ws1", "l1", leadIDs, false))
+
+	nonDialable, err := GetNonDialableLeadIDs("ws1", "l1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, leadIDs, nonDialable)
+
+	lead, err := GetLeadByID(context.Background(), "ws1", "l1", "lead1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, lead.CallCount)
+	assert.NotNil(t, lead.LastCallDate)
+
+	require.NoError(t, BatchUpdateLeadsDialable("ws1", "l1", leadIDs, true))
+
+	lead, err = GetLeadByID(context.Background(), "ws1", "l1", "lead1")
+	require.NoError(t, err)
+	assert.True(t, lead.Dialable)
+	assert.Equal(t, 2, lead.CallCount)
+}
+
+// TestGetUndispositionedLeadIDsSchema tests a lead taken again after an
+// earlier call is undispositioned until its new call status is written
+func TestGetUndispositionedLeadIDsSchema(t *testing.T) {
+	session := schemaSession(t)
+	ctx := context.Background()
+
+	insert := "INSERT INTO list_data (workspace_id, listnumber, leadid, phonenumber, callcount, dialable) VALUES (?, ?, ?, ?, ?, ?)"
+	require.NoError(t, session.Query(insert, "ws1", "l1", "lead1", "5550100", 0, true).Exec())
+
+	// an earlier call, then the lead is taken again
+	require.NoError(t, UpdateLeadStatus("ws1", "l1", "lead1", "no_answer"))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, UpdateLeadDialStatus(ctx, "ws1", "l1", "lead1", false))
+
+	leadIDs, err := GetUndispositionedLeadIDs("ws1", "l1", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lead1"}, leadIDs)
+
+	leadIDs, err = GetUndispositionedLeadIDs("ws1", "l1", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, leadIDs)
+
+	require.NoError(t, UpdateLeadStatus("ws1", "l1", "lead1", "answered"))
+
+	leadIDs, err = GetUndispositionedLeadIDs("ws1", "l1", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, leadIDs)
+}
+
+// TestGetDueCallbacksSchema tests due callbacks are read between the bounds
+// and that cancelled ones are left out
+func TestGetDueCallbacksSchema(t *testing.T) {
+	schemaSession(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+
+	old, err := CreateCallback(Callback{WorkspaceID: "ws1", CampaignID: "c1", ListNumber: "l1", LeadID: "lead1", DueAt: now.Add(-48 * time.Hour)})
+	require.NoError(t, err)
+	due, err := CreateCallback(Callback{WorkspaceID: "ws1", CampaignID: "c1", ListNumber: "l1", LeadID: "lead2", DueAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+	cancelled, err := CreateCallback(Callback{WorkspaceID: "ws1", CampaignID: "c1", ListNumber: "l1", LeadID: "lead3", DueAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+	_, err = CreateCallback(Callback{WorkspaceID: "ws1", CampaignID: "c1", ListNumber: "l1", LeadID: "lead4", DueAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+
+	require.NoError(t, UpdateCallbackStatus(ctx, *cancelled, CallbackCancelled))
+
+	callbacks, err := GetDueCallbacks(ctx, "ws1", now.Add(-24*time.Hour), now)
+	require.NoError(t, err)
+	require.Len(t, callbacks, 1)
+	assert.Equal(t, due.ID, callbacks[0].ID)
+
+	callbacks, err = GetDueCallbacks(ctx, "ws1", now.Add(-72*time.Hour), now)
+	require.NoError(t, err)
+	assert.Len(t, callbacks, 2)
+	assert.NotEqual(t, callbacks[0].ID, callbacks[1].ID)
+	assert.Contains(t, []string{callbacks[0].ID, callbacks[1].ID}, old.ID)
+}
+
+// TestLeadAuditEventsSchema tests the events of a lead and the campaign wide
+// ones are read back from their own partitions
+func TestLeadAuditEventsSchema(t *testing.T) {
+	schemaSession(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, RecordAuditEvents(ctx,
+		AuditEvent{WorkspaceID: "ws1", At: now, Type: AuditInjected, CampaignID: "c1", ListNumber: "l1", LeadID: "lead1"},
+		AuditEvent{WorkspaceID: "ws1", At: now, Type: AuditInjected, CampaignID: "c1", ListNumber: "l1", LeadID: "lead2"},
+		AuditEvent{WorkspaceID: "ws1", At: now, Type: AuditSkipped, Reason: AuditReasonRateExhausted, CampaignID: "c1"},
+	))
+
+	events, err := GetLeadAuditEvents(ctx, "ws1", "lead1", now)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, AuditInjected, events[0].Type)
+	assert.Equal(t, "l1", events[0].ListNumber)
+
+	events, err = GetCampaignAuditEvents(ctx, "ws1", "c1", now)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, AuditReasonRateExhausted, events[0].Reason)
+
+	events, err = GetAuditEvents(ctx, "ws1", now)
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+}
@@ -0,0 +1,102 @@
+package hopper
+
+import (
+	"sort"
+	"time"
+
+	"github.com/nico-phil/process/db"
+)
+
+// listAllocation is the number of leads to inject from a single list
+type listAllocation struct {
+	List  db.List
+	Count int
+}
+
+// allocateLeads splits budget across lists. Lists are drained by priority
+// (highest first); inside a priority tier each list gets a share of the
+// budget proportional to its dialable lead count scaled by its weight.
+// dailyRemaining holds how many more leads a capped list may inject today,
+// lists without an entry are uncapped.
+func allocateLeads(lists []db.List, leadsCount map[string]int, dailyRemaining map[string]int, budget int, now time.Time) []listAllocation {
+	tiers := map[int][]db.List{}
+	for _, list := range lists {
+		if !list.IsActiveAt(now) || leadsCount[list.ListNumber] == 0 {
+			continue
+		}
+
+		if remaining, ok := dailyRemaining[list.ListNumber]; ok && remaining <= 0 {
+			continue
+		}
+
+		tiers[list.Priority] = append(tiers[list.Priority], list)
+	}
+
+	priorities := make([]int, 0, len(tiers))
+	for priority := range tiers {
+		priorities = append(priorities, priority)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+
+	allocations := []listAllocation{}
+	remainingToInject := budget
+
+	for _, priority := range priorities {
+		if remainingToInject <= 0 {
+			break
+		}
+
+		tier := tiers[priority]
+		totalWeighted := 0
+		for _, list := range tier {
+			totalWeighted += listWeight(list) * leadsCount[list.ListNumber]
+		}
+
+		tierBudget := remainingToInject
+		for _, list := range tier {
+			if remainingToInject <= 0 {
+				break
+			}
+
+			listLeadCount := leadsCount[list.ListNumber]
+
+			// Calculate weighted share for this list
+			proportion := float64(listWeight(list)*listLeadCount) / float64(totalWeighted)
+			listInjectCount := int(float64(tierBudget) * proportion)
+
+			// Ensure minimum of 1 if there are leads and capacity
+			if listInjectCount < 1 {
+				listInjectCount = 1
+			}
+
+			// Never ask for more leads than the list has
+			if listInjectCount > listLeadCount {
+				listInjectCount = listLeadCount
+			}
+
+			// Respect the list's daily cap
+			if remaining, ok := dailyRemaining[list.ListNumber]; ok && listInjectCount > remaining {
+				listInjectCount = remaining
+			}
+
+			// Ensure we don't exceed remaining capacity
+			if listInjectCount > remainingToInject {
+				listInjectCount = remainingToInject
+			}
+
+			allocations = append(allocations, listAllocation{List: list, Count: listInjectCount})
+			remainingToInject -= listInjectCount
+		}
+	}
+
+	return allocations
+}
+
+// listWeight returns the list weight, lists without a weight count as 1
+func listWeight(list db.List) int {
+	if list.Weight <= 0 {
+		return 1
+	}
+
+	return list.Weight
+}
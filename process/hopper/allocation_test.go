@@ -0,0 +1,92 @@
+package hopper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nico-phil/process/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAllocateLeads_Proportional tests that lists without weights share the budget by lead count
+func TestAllocateLeads_Proportional(t *testing.T) {
+	lists := []db.List{
+		{ListNumber: "1", Active: true},
+		{ListNumber: "2", Active: true},
+	}
+	counts := map[string]int{"1": 300, "2": 100}
+
+	allocations := allocateLeads(lists, counts, map[string]int{}, 100, time.Now())
+
+	assert.Len(t, allocations, 2)
+	assert.Equal(t, 75, allocations[0].Count)
+	assert.Equal(t, 25, allocations[1].Count)
+}
+
+// TestAllocateLeads_Weight tests that weight scales a list's share
+func TestAllocateLeads_Weight(t *testing.T) {
+	lists := []db.List{
+		{ListNumber: "1", Active: true, Weight: 3},
+		{ListNumber: "2", Active: true, Weight: 1},
+	}
+	counts := map[string]int{"1": 100, "2": 100}
+
+	allocations := allocateLeads(lists, counts, map[string]int{}, 100, time.Now())
+
+	assert.Len(t, allocations, 2)
+	assert.Equal(t, 75, allocations[0].Count)
+	assert.Equal(t, 25, allocations[1].Count)
+}
+
+// TestAllocateLeads_Priority tests that higher priority lists are drained first
+func TestAllocateLeads_Priority(t *testing.T) {
+	lists := []db.List{
+		{ListNumber: "low", Active: true, Priority: 1},
+		{ListNumber: "hot", Active: true, Priority: 10},
+	}
+	counts := map[string]int{"low": 500, "hot": 40}
+
+	allocations := allocateLeads(lists, counts, map[string]int{}, 100, time.Now())
+
+	assert.Len(t, allocations, 2)
+	assert.Equal(t, "hot", allocations[0].List.ListNumber)
+	assert.Equal(t, 40, allocations[0].Count)
+	assert.Equal(t, "low", allocations[1].List.ListNumber)
+	assert.Equal(t, 60, allocations[1].Count)
+}
+
+// TestAllocateLeads_DailyCap tests that capped lists never exceed their remaining allowance
+func TestAllocateLeads_DailyCap(t *testing.T) {
+	lists := []db.List{
+		{ListNumber: "1", Active: true, MaxCallsPerDay: 50},
+		{ListNumber: "2", Active: true, MaxCallsPerDay: 50},
+	}
+	counts := map[string]int{"1": 100, "2": 100}
+	remaining := map[string]int{"1": 10, "2": 0}
+
+	allocations := allocateLeads(lists, counts, remaining, 100, time.Now())
+
+	assert.Len(t, allocations, 1)
+	assert.Equal(t, "1", allocations[0].List.ListNumber)
+	assert.Equal(t, 10, allocations[0].Count)
+}
+
+// TestAllocateLeads_DateRange tests that lists outside their active range are skipped
+func TestAllocateLeads_DateRange(t *testing.T) {
+	now := time.Now()
+	tomorrow := now.Add(24 * time.Hour)
+	yesterday := now.Add(-24 * time.Hour)
+
+	lists := []db.List{
+		{ListNumber: "future", Active: true, ActiveFrom: &tomorrow},
+		{ListNumber: "expired", Active: true, ActiveUntil: &yesterday},
+		{ListNumber: "current", Active: true, ActiveFrom: &yesterday, ActiveUntil: &tomorrow},
+	}
+	counts := map[string]int{"future": 10, "expired": 10, "current": 10}
+
+	allocations := allocateLeads(lists, counts, map[string]int{}, 100, now)
+
+	assert.Len(t, allocations, 1)
+	assert.Equal(t, "current", allocations[0].List.ListNumber)
+	assert.Equal(t, 10, allocations[0].Count)
+}
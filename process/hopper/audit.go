@@ -0,0 +1,74 @@
+package hopper
+
+import (
+	"context"
+	"time"
+
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/redis"
+)
+
+// recordAudit appends events to the audit history with the id of the
+// current cycle. A failure is logged, it never stops the cycle.
+func recordAudit(ctx context.Context, events ...db.AuditEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	cycleID := logging.CycleIDFromContext(ctx)
+	for i := range events {
+		events[i].CycleID = cycleID
+	}
+
+	if err := db.RecordAuditEvents(ctx, events...); err != nil {
+		logging.FromContext(ctx, logger).Error("failed to record audit events", "count", len(events), "error", err)
+	}
+}
+
+// auditCampaignSkip records why a campaign injects nothing. Cycles run every
+// few seconds, so the reason is only recorded when it changes.
+func (qm *QueueManager) auditCampaignSkip(ctx context.Context, campaign db.Campaign, reason, detail string) {
+	qm.skipsMu.Lock()
+	previous := qm.campaignSkips[campaign.ID]
+	qm.campaignSkips[campaign.ID] = reason
+	qm.skipsMu.Unlock()
+
+	if previous == reason {
+		return
+	}
+
+	recordAudit(ctx, db.AuditEvent{
+		WorkspaceID: campaign.WorkspaceID,
+		Type:        db.AuditSkipped,
+		Reason:      reason,
+		CampaignID:  campaign.ID,
+		Detail:      detail,
+	})
+}
+
+// clearCampaignSkip forgets the last skip reason of a campaign once it injects again
+func (qm *QueueManager) clearCampaignSkip(campaignID string) {
+	qm.skipsMu.Lock()
+	defer qm.skipsMu.Unlock()
+	delete(qm.campaignSkips, campaignID)
+}
+
+// RecordDequeued records the leads a dialer popped from a workspace queue in
+// the audit history. It is registered with redis.OnDequeue.
+func RecordDequeued(workspaceID string, leads []redis.QueuedLead) {
+	now := time.Now()
+	events := make([]db.AuditEvent, 0, len(leads))
+	for _, lead := range leads {
+		events = append(events, db.AuditEvent{
+			WorkspaceID: workspaceID,
+			At:          now,
+			Type:        db.AuditDequeued,
+			CampaignID:  lead.CampaignID,
+			ListNumber:  lead.ListNumber,
+			LeadID:      lead.LeadID,
+		})
+	}
+
+	recordAudit(context.Background(), events...)
+}
@@ -0,0 +1,144 @@
+package hopper
+
+import (
+	"context"
+	"time"
+
+	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/redis"
+)
+
+// callbackLookback is how overdue a callback may be and still be dialed,
+// older pending callbacks expire with their row
+const callbackLookback = 24 * time.Hour
+
+// InjectDueCallbacks pushes the due callbacks of a workspace ahead of the
+// leads already queued. Only callbacks of the given campaigns, those active
+// and in their schedule, are injected, and no more per campaign than its rate
+// budget. The others are left pending for a later cycle, and so are the
+// callbacks of a lead still queued. The callback of a lead with a terminal
+// status is cancelled. The lead is taken before it is queued, and a callback
+// is queued at most once even when its status could not be saved.
+func (qm *QueueManager) InjectDueCallbacks(ctx context.Context, workspaceID string, campaigns []db.Campaign) (int, error) {
+	log := logging.FromContext(ctx, logger).With(logging.WorkspaceID, workspaceID)
+
+	now := time.Now()
+	callbacks, err := db.GetDueCallbacks(ctx, workspaceID, now.Add(-callbackLookback), now)
+	if err != nil {
+		log.Error("failed to get due callbacks", "error", err)
+		return 0, err
+	}
+
+	if len(callbacks) == 0 {
+		return 0, nil
+	}
+
+	queuedLeads, err := redis.GetQueuedLeadIDs(workspaceID)
+	if err != nil {
+		log.Error("failed to get queued leads", "error", err)
+		return 0, err
+	}
+
+	byID := map[string]db.Campaign{}
+	for _, campaign := range campaigns {
+		byID[campaign.ID] = campaign
+	}
+
+	budgets := map[string]int{}
+	injected := 0
+	var events []db.AuditEvent
+	defer func() { recordAudit(ctx, events...) }()
+
+	for _, callback := range callbacks {
+		campaign, ok := byID[callback.CampaignID]
+		if !ok {
+			continue
+		}
+
+		if _, ok := budgets[campaign.ID]; !ok {
+			budgets[campaign.ID] = qm.callbackBudget(ctx, campaign)
+		}
+		if budgets[campaign.ID] <= 0 || queuedLeads[callback.LeadID] {
+			continue
+		}
+
+		lead, err := db.GetLeadByID(ctx, workspaceID, callback.ListNumber, callback.LeadID)
+		if err != nil {
+			log.Error("failed to get lead for callback", logging.LeadID, callback.LeadID, "callback_id", callback.ID, "error", err)
+			continue
+		}
+
+		if db.IsTerminalLeadStatus(lead.CallStatus) {
+			log.Warn("cancelled callback of terminal lead", logging.LeadID, callback.LeadID, "callback_id", callback.ID, "status", lead.CallStatus)
+			if err := db.UpdateCallbackStatus(ctx, callback, db.CallbackCancelled); err != nil {
+				log.Error("failed to cancel callback", "callback_id", callback.ID, "error", err)
+			}
+			continue
+		}
+
+		// take the lead so a regular injection does not queue it as well
+		if lead.Dialable {
+			if err := db.UpdateLeadDialStatus(ctx, workspaceID, callback.ListNumber, callback.LeadID, false); err != nil {
+				log.Error("failed to take lead for callback", logging.LeadID, callback.LeadID, "callback_id", callback.ID, "error", err)
+				continue
+			}
+		}
+
+		queuedLead := newQueuedLead(callback.CampaignID, *lead, now)
+		queuedLead.CallbackID = callback.ID
+		queuedLead.AgentID = callback.AgentID
+		queuedLead.ExpiresAt = qm.leadExpiry(campaign, lead.ZipCode, now, config.GetQueuedLeadTTL())
+
+		queued, err := redis.QueueCallback(ctx, workspaceID, queuedLead)
+		if err != nil {
+			log.Error("failed to queue callback", "callback_id", callback.ID, "error", err)
+			continue
+		}
+
+		if err := db.UpdateCallbackStatus(ctx, callback, db.CallbackQueued); err != nil {
+			log.Error("failed to mark callback as queued", "callback_id", callback.ID, "error", err)
+		}
+
+		if !queued {
+			continue
+		}
+
+		budgets[campaign.ID]--
+		events = append(events, db.AuditEvent{
+			WorkspaceID: workspaceID,
+			Type:        db.AuditInjected,
+			CampaignID:  callback.CampaignID,
+			ListNumber:  callback.ListNumber,
+			LeadID:      callback.LeadID,
+			Detail:      "callback " + callback.ID,
+		})
+
+		injected++
+	}
+
+	if injected > 0 {
+		log.Info("injected due callbacks", "count", injected)
+	}
+
+	return injected, nil
+}
+
+// callbackBudget returns how many callbacks of a campaign the cycle may
+// queue: the injection budget the rate controller would give the campaign,
+// or the default inject cap without one. Callbacks queued out of it are
+// counted in the queue depth of the campaign's regular injection.
+func (qm *QueueManager) callbackBudget(ctx context.Context, campaign db.Campaign) int {
+	if qm.rateController == nil {
+		return config.GetDefaultInjectCap()
+	}
+
+	calculation, err := qm.rateController.PreviewInjection(ctx, campaign)
+	if err != nil {
+		logging.FromContext(ctx, logger).Error("failed to calculate callback budget", logging.CampaignID, campaign.ID, "error", err)
+		return 0
+	}
+
+	return calculation.InjectCount
+}
@@ -0,0 +1,229 @@
+package hopper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/ratelimit"
+	"github.com/nico-phil/process/tz"
+)
+
+// SkipReason explains why a dry run would not inject a campaign, list or lead
+type SkipReason string
+
+const (
+	// SkipCampaignInactive means the campaign is not active
+	SkipCampaignInactive SkipReason = "campaign_inactive"
+	// SkipOutsideSchedule means the campaign dialing hours or days exclude now
+	SkipOutsideSchedule SkipReason = "outside_schedule"
+	// SkipNoActiveLists means the campaign has no active list
+	SkipNoActiveLists SkipReason = "no_active_lists"
+	// SkipNoDialableLeads means no list of the campaign has a dialable lead
+	SkipNoDialableLeads SkipReason = "no_dialable_leads"
+	// SkipRateExhausted means the rate controller allows no injection for the next window
+	SkipRateExhausted SkipReason = "rate_exhausted"
+	// SkipListOutsideDates means the list active date range excludes now
+	SkipListOutsideDates SkipReason = "list_outside_dates"
+	// SkipDailyCapReached means the list has injected its daily maximum
+	SkipDailyCapReached SkipReason = "daily_cap_reached"
+	// SkipBudgetAllocated means higher priority lists took the whole budget
+	SkipBudgetAllocated SkipReason = "budget_allocated"
+	// SkipBadPhone means the lead phone number cannot be dialed
+	SkipBadPhone SkipReason = "bad_phone"
+	// SkipOutsideWindow means the dialing window is closed in the lead's local time
+	SkipOutsideWindow SkipReason = "outside_window"
+)
+
+// DryRunReport is what a cycle would inject for a workspace
+type DryRunReport struct {
+	WorkspaceID string           `json:"workspace_id"`
+	At          time.Time        `json:"at"`
+	Injected    int              `json:"injected"`
+	Campaigns   []CampaignReport `json:"campaigns"`
+}
+
+// CampaignReport is what a cycle would inject for a campaign
+type CampaignReport struct {
+	CampaignID      string       `json:"campaign_id"`
+	Budget          int          `json:"budget"`
+	ComplianceState string       `json:"compliance_state,omitempty"`
+	Injected        int          `json:"injected"`
+	Skipped         SkipReason   `json:"skipped,omitempty"`
+	Lists           []ListReport `json:"lists,omitempty"`
+}
+
+// ListReport is what a cycle would inject from a list
+type ListReport struct {
+	ListNumber     string        `json:"list_number"`
+	Priority       int           `json:"priority"`
+	Weight         int           `json:"weight"`
+	Dialable       int           `json:"dialable"`
+	DailyRemaining *int          `json:"daily_remaining,omitempty"`
+	Allocated      int           `json:"allocated"`
+	Skipped        SkipReason    `json:"skipped,omitempty"`
+	Leads          []DryRunLead  `json:"leads,omitempty"`
+	SkippedLeads   []SkippedLead `json:"skipped_leads,omitempty"`
+}
+
+// DryRunLead is a lead a cycle would queue
+type DryRunLead struct {
+	LeadID      string     `json:"lead_id"`
+	PhoneNumber string     `json:"phone_number"`
+	ZipCode     string     `json:"zip_code"`
+	LocalTime   *time.Time `json:"local_time,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+}
+
+// SkippedLead is a dialable lead a cycle would pick but not dial
+type SkippedLead struct {
+	LeadID string     `json:"lead_id"`
+	Reason SkipReason `json:"reason"`
+}
+
+// DryRunWorkspace runs the selection of a cycle for one workspace, campaign
+// schedule, rate budget, list allocation and lead time zones, and reports
+// what would be injected and what would be skipped. It writes nothing to
+// cassandra or redis and leaves the metrics alone. Due callbacks are not
+// part of the report.
+func (qm *QueueManager) DryRunWorkspace(ctx context.Context, workspaceID string) (*DryRunReport, error) {
+	ctx = logging.WithLogger(ctx, logger.With(logging.WorkspaceID, workspaceID, "dry_run", true))
+
+	campaigns, err := db.GetCampaignsByWorkspace(workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaigns for workspace %s: %v", workspaceID, err)
+	}
+
+	var active []db.Campaign
+	for _, campaign := range campaigns {
+		if campaign.Active {
+			active = append(active, campaign)
+		}
+	}
+
+	inSchedule := map[string]bool{}
+	for _, campaign := range qm.GetActiveCampignsWithSchedule(workspaceID, active) {
+		inSchedule[campaign.ID] = true
+	}
+
+	report := &DryRunReport{WorkspaceID: workspaceID, At: time.Now(), Campaigns: []CampaignReport{}}
+	for _, campaign := range campaigns {
+		campaignReport := CampaignReport{CampaignID: campaign.ID}
+
+		switch {
+		case !campaign.Active:
+			campaignReport.Skipped = SkipCampaignInactive
+		case !inSchedule[campaign.ID]:
+			campaignReport.Skipped = SkipOutsideSchedule
+		default:
+			campaignReport, err = qm.dryRunCampaign(ctx, campaign, report.At)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		report.Injected += campaignReport.Injected
+		report.Campaigns = append(report.Campaigns, campaignReport)
+	}
+
+	return report, nil
+}
+
+// dryRunCampaign plans a campaign the way ProcessCampaignWithContext does
+// and reads the leads each list would give, without queuing them
+func (qm *QueueManager) dryRunCampaign(ctx context.Context, campaign db.Campaign, now time.Time) (CampaignReport, error) {
+	report := CampaignReport{CampaignID: campaign.ID}
+
+	var calculate func(context.Context, db.Campaign) (*ratelimit.RateCalculation, error)
+	if qm.rateController != nil {
+		calculate = qm.rateController.PreviewInjection
+	}
+
+	plan, err := planCampaign(ctx, campaign, now, calculate)
+	if err != nil {
+		return report, err
+	}
+
+	report.Budget = plan.budget
+	if plan.rate != nil {
+		report.ComplianceState = plan.rate.ComplianceState
+	}
+
+	switch {
+	case len(plan.lists) == 0:
+		report.Skipped = SkipNoActiveLists
+		return report, nil
+	case plan.available == 0:
+		report.Skipped = SkipNoDialableLeads
+	case plan.budget <= 0:
+		report.Skipped = SkipRateExhausted
+	}
+
+	allocated := map[string]int{}
+	for _, allocation := range plan.allocations {
+		allocated[allocation.List.ListNumber] = allocation.Count
+	}
+
+	for _, list := range plan.lists {
+		listReport := ListReport{
+			ListNumber: list.ListNumber,
+			Priority:   list.Priority,
+			Weight:     listWeight(list),
+			Dialable:   plan.leadsCount[list.ListNumber],
+			Allocated:  allocated[list.ListNumber],
+		}
+
+		remaining, capped := plan.dailyRemaining[list.ListNumber]
+		if capped {
+			listReport.DailyRemaining = &remaining
+		}
+
+		switch {
+		case !list.IsActiveAt(now):
+			listReport.Skipped = SkipListOutsideDates
+		case listReport.Dialable == 0:
+			listReport.Skipped = SkipNoDialableLeads
+		case capped && remaining <= 0:
+			listReport.Skipped = SkipDailyCapReached
+		case report.Skipped != "":
+			listReport.Skipped = report.Skipped
+		case listReport.Allocated == 0:
+			listReport.Skipped = SkipBudgetAllocated
+		default:
+			selections, err := qm.selectLeads(ctx, campaign, list.ListNumber, listReport.Allocated, now)
+			if err != nil {
+				return report, err
+			}
+
+			for _, selection := range selections {
+				if selection.skipped != "" {
+					listReport.SkippedLeads = append(listReport.SkippedLeads, SkippedLead{LeadID: selection.lead.LeadID, Reason: selection.skipped})
+					continue
+				}
+
+				dryRunLead := DryRunLead{
+					LeadID:      selection.lead.LeadID,
+					PhoneNumber: selection.lead.PhoneNumber,
+					ZipCode:     selection.lead.ZipCode,
+					ExpiresAt:   selection.queued.ExpiresAt,
+				}
+
+				if qm.zipCodeCache != nil {
+					if localTime, err := tz.GetLocalTimeAt(qm.zipCodeCache, selection.lead.ZipCode, now); err == nil {
+						dryRunLead.LocalTime = &localTime
+					}
+				}
+
+				listReport.Leads = append(listReport.Leads, dryRunLead)
+			}
+
+			report.Injected += len(listReport.Leads)
+		}
+
+		report.Lists = append(report.Lists, listReport)
+	}
+
+	return report, nil
+}
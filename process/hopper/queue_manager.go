@@ -2,32 +2,78 @@ package hopper
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/nico-phil/process/config"
 	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/metrics"
 	"github.com/nico-phil/process/ratelimit"
+	"github.com/nico-phil/process/redis"
+	"github.com/nico-phil/process/tracing"
+	"github.com/nico-phil/process/tz"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	logger = logging.For("hopper")
+	tracer = tracing.Tracer("hopper")
+)
+
+var (
+	// ErrListNotInCampaign is returned when dialing a lead through a campaign
+	// its list does not belong to
+	ErrListNotInCampaign = errors.New("list does not belong to the campaign")
+	// ErrLeadNotDialable is returned when dialing a lead already queued or
+	// with a terminal call status
+	ErrLeadNotDialable = errors.New("lead is queued or has a terminal status")
+	// ErrOutsideDialWindow is returned when dialing a lead whose dialing
+	// window is closed in its local time
+	ErrOutsideDialWindow = errors.New("dialing window is closed for the lead")
 )
 
 // QueueManager manages the hopper  queue system
 type QueueManager struct {
 	rateController *ratelimit.RateController
-	// timeZoneValidation
+	zipCodeCache   *tz.ZipCodeCache
+
+	// campaignSkips holds the last audited skip reason of each campaign
+	skipsMu       sync.Mutex
+	campaignSkips map[string]string
 }
 
 // NewQueueManager created a new queue manager
-func NewQueueManager(rateController *ratelimit.RateController) *QueueManager {
+func NewQueueManager(rateController *ratelimit.RateController, zipCodeCache *tz.ZipCodeCache) *QueueManager {
 	return &QueueManager{
 		rateController: rateController,
+		zipCodeCache:   zipCodeCache,
+		campaignSkips:  map[string]string{},
 	}
 }
 
 // ProcessAllWorkspacesWithContext process all worspaces
-func (qm *QueueManager) ProcessAllWorkspacesWithContext(ctx context.Context) error {
-	campaigns, err := db.GetAllCampaigns()
+func (qm *QueueManager) ProcessAllWorkspacesWithContext(ctx context.Context) (err error) {
+	start := time.Now()
+	cycleID := logging.NewCycleID()
+	ctx, span := tracer.Start(ctx, "hopper.cycle", trace.WithAttributes(attribute.String(logging.CycleID, cycleID)))
+	totalWorkspaces, processedWorkspaces := 0, 0
+	defer func() {
+		metrics.CycleDuration.Observe(time.Since(start).Seconds())
+		recordCycle(start, totalWorkspaces, processedWorkspaces, err)
+		tracing.End(span, err)
+	}()
+
+	log := logger.With(logging.CycleID, cycleID)
+	ctx = logging.WithLogger(logging.WithCycleID(ctx, cycleID), log)
+
+	campaigns, err := db.GetAllCampaigns(ctx)
 	if err != nil {
-		log.Printf("failed to get campaign from db %v", err)
+		log.Error("failed to get campaigns", "error", err)
 		return err
 	}
 
@@ -39,45 +85,96 @@ func (qm *QueueManager) ProcessAllWorkspacesWithContext(ctx context.Context) err
 		}
 	}
 
-	totalWorkspaces := len(workspaces)
-	processedWorkspaces := 0
+	totalWorkspaces = len(workspaces)
 
 	for workspaceID, campaign := range workspaces {
-		err := qm.ProcessWorkspaceWithContext(ctx, workspaceID, campaign)
-		if err != nil {
-			log.Printf("failed to process workspace %s: %v", workspaceID, err)
+		if qm.rateController != nil {
+			if err := qm.rateController.PublishRateLimits(ctx, workspaceID, campaign); err != nil {
+				log.Error("failed to publish rate limits", logging.WorkspaceID, workspaceID, "error", err)
+			}
+		}
+
+		if err := qm.ProcessWorkspaceWithContext(ctx, workspaceID, campaign); err != nil {
+			log.Error("failed to process workspace", logging.WorkspaceID, workspaceID, "error", err)
 			continue
 		}
 
-		log.Printf("successfully process workspace %s", workspaceID)
+		log.Debug("processed workspace", logging.WorkspaceID, workspaceID)
 
 		processedWorkspaces++
 	}
-	log.Printf("processed %d/%d workspaces succesfully", processedWorkspaces, totalWorkspaces)
+	span.SetAttributes(attribute.Int("workspaces", totalWorkspaces), attribute.Int("processed", processedWorkspaces))
+	log.Info("hopper cycle done", "processed", processedWorkspaces, "workspaces", totalWorkspaces, "duration", time.Since(start))
 	return nil
 }
 
+// ProcessWorkspaceByID runs a single injection cycle for one workspace
+// outside of the hopper loop, e.g. from the command line
+func (qm *QueueManager) ProcessWorkspaceByID(ctx context.Context, workspaceID string) error {
+	cycleID := logging.NewCycleID()
+	ctx = logging.WithLogger(logging.WithCycleID(ctx, cycleID), logger.With(logging.CycleID, cycleID))
+
+	campaigns, err := db.GetCampaignsByWorkspace(workspaceID)
+	if err != nil {
+		return fmt.Errorf("failed to get campaigns for workspace %s: %v", workspaceID, err)
+	}
+
+	var active []db.Campaign
+	for _, campaign := range campaigns {
+		if campaign.Active {
+			active = append(active, campaign)
+		}
+	}
+
+	if len(active) == 0 {
+		return fmt.Errorf("workspace %s has no active campaign", workspaceID)
+	}
+
+	if qm.rateController != nil {
+		if err := qm.rateController.PublishRateLimits(ctx, workspaceID, active); err != nil {
+			return fmt.Errorf("failed to publish rate limits: %v", err)
+		}
+	}
+
+	return qm.ProcessWorkspaceWithContext(ctx, workspaceID, active)
+}
+
 // ProcessWorkspaceWithContext processes  a single workspace with context
 func (qm *QueueManager) ProcessWorkspaceWithContext(ctx context.Context, worksapceID string, campaigns []db.Campaign) error {
+	ctx, span := tracer.Start(ctx, "hopper.workspace", trace.WithAttributes(attribute.String(logging.WorkspaceID, worksapceID)))
+	defer span.End()
+
+	log := logging.FromContext(ctx, logger).With(logging.WorkspaceID, worksapceID)
+	ctx = logging.WithLogger(ctx, log)
 
 	activeCampgaignWithSchedule := qm.GetActiveCampignsWithSchedule(worksapceID, campaigns)
-	log.Printf("activeCampgaignWithSchedule for %s %v\n", worksapceID, activeCampgaignWithSchedule)
 
+	// callbacks are due at a fixed time, they go ahead of the regular leads
+	if _, err := qm.InjectDueCallbacks(ctx, worksapceID, activeCampgaignWithSchedule); err != nil {
+		log.Error("failed to inject callbacks", "error", err)
+	}
+
+	inSchedule := map[string]bool{}
+	for _, campaign := range activeCampgaignWithSchedule {
+		inSchedule[campaign.ID] = true
+	}
+	for _, campaign := range campaigns {
+		if !inSchedule[campaign.ID] {
+			qm.auditCampaignSkip(ctx, campaign, db.AuditReasonOutsideWindow, "campaign schedule")
+		}
+	}
 	if len(activeCampgaignWithSchedule) == 0 {
-		log.Printf("no active campaign found for workspace %s", worksapceID)
+		log.Debug("no active campaign in schedule")
 		return nil
 	}
 
-	log.Printf("found %d active campaigns for %s", len(activeCampgaignWithSchedule), worksapceID)
+	log.Debug("found active campaigns in schedule", "count", len(activeCampgaignWithSchedule))
 
 	for _, campaign := range activeCampgaignWithSchedule {
 		// process the campaign for the worskspace
-		injected, err := qm.ProcessCampaignWithContext(ctx, campaign)
-		if err != nil {
-
+		if _, err := qm.ProcessCampaignWithContext(ctx, campaign); err != nil {
+			log.Error("failed to process campaign", logging.CampaignID, campaign.ID, "error", err)
 		}
-		fmt.Println(injected)
-
 	}
 	return nil
 }
@@ -101,87 +198,436 @@ func (qm *QueueManager) GetActiveCampignsWithSchedule(worksapceID string, campai
 }
 
 // ProcessCampaignWithContext processes a single campaign with context
-func (qm *QueueManager) ProcessCampaignWithContext(ctx context.Context, campaign db.Campaign) (int, error) {
-	log.Printf("processing campaign %s", campaign.ID)
+func (qm *QueueManager) ProcessCampaignWithContext(ctx context.Context, campaign db.Campaign) (injected int, err error) {
+	ctx, span := tracer.Start(ctx, "hopper.campaign", trace.WithAttributes(
+		attribute.String(logging.WorkspaceID, campaign.WorkspaceID),
+		attribute.String(logging.CampaignID, campaign.ID),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("injected", injected))
+		tracing.End(span, err)
+	}()
+
+	log := logging.FromContext(ctx, logger).With(logging.WorkspaceID, campaign.WorkspaceID, logging.CampaignID, campaign.ID)
+	ctx = logging.WithLogger(ctx, log)
+	log.Debug("processing campaign")
+
+	var calculate func(context.Context, db.Campaign) (*ratelimit.RateCalculation, error)
+	if qm.rateController != nil {
+		calculate = qm.rateController.CalculateInjection
+	}
+
+	plan, err := planCampaign(ctx, campaign, time.Now(), calculate)
+	if err != nil {
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int("budget", plan.budget))
+
+	// a campaign with dialable leads left but no budget is rate exhausted
+	if len(plan.lists) > 0 && plan.available > 0 && plan.budget <= 0 {
+		qm.auditCampaignSkip(ctx, campaign, db.AuditReasonRateExhausted, "")
+	} else {
+		qm.clearCampaignSkip(campaign.ID)
+	}
+
+	switch {
+	case len(plan.lists) == 0:
+		log.Debug("no active lists")
+		return 0, nil
+	case plan.available == 0:
+		log.Debug("no dialable lead available")
+		return 0, nil
+	case plan.budget <= 0:
+		log.Debug("no capacity to inject leads")
+		return 0, nil
+	}
+
+	// inject leads across lists by priority, weight and daily cap
+	totalInjected := 0
+	for _, allocation := range plan.allocations {
+		injected, err := qm.InjectLeadsFromList(ctx, campaign, allocation.List, allocation.Count)
+		if err != nil {
+			log.Error("failed to inject leads", logging.ListNumber, allocation.List.ListNumber, "error", err)
+			continue
+		}
+
+		totalInjected += injected
+	}
+
+	return totalInjected, nil
+}
+
+// campaignPlan is what a cycle reads before injecting the leads of a campaign
+type campaignPlan struct {
+	lists          []db.List
+	leadsCount     map[string]int
+	available      int
+	dailyRemaining map[string]int
+	rate           *ratelimit.RateCalculation
+	budget         int
+	allocations    []listAllocation
+}
+
+// planCampaign reads the active lists of a campaign, their dialable leads
+// and daily allowance, asks calculate for the rate budget and splits it
+// across the lists. It stops early when there is no list or no dialable
+// lead. Without calculate the default inject cap is the budget.
+func planCampaign(ctx context.Context, campaign db.Campaign, now time.Time, calculate func(context.Context, db.Campaign) (*ratelimit.RateCalculation, error)) (*campaignPlan, error) {
+	log := logging.FromContext(ctx, logger)
 
 	// get all list for this spcecific campaign
 	lists, err := db.GetActiveListByCampaign(ctx, campaign.ID)
 	if err != nil {
-		log.Printf("failed get lists for campaign: %s with error: %v", campaign.ID, err)
-		return 0, fmt.Errorf("failed to get lists for campaign: %s with error: %v", campaign.ID, err)
+		return nil, fmt.Errorf("failed to get lists for campaign: %s with error: %v", campaign.ID, err)
 	}
 
+	plan := &campaignPlan{lists: lists, dailyRemaining: map[string]int{}}
 	if len(lists) == 0 {
-		log.Printf("No active lists found for campaign %s", campaign.ID)
-		return 0, nil
+		return plan, nil
 	}
 
-	leadsCount, err := db.GetLeadsCount(campaign.WorkspaceID)
+	plan.leadsCount, err = db.GetLeadCounts(ctx, campaign.WorkspaceID)
 	if err != nil {
-		log.Printf("error")
+		log.Error("failed to count dialable leads", "error", err)
 	}
-	fmt.Println("leadsCount:", leadsCount)
-
-	totalLeadsAvailable := 0
 
 	for _, list := range lists {
-		count, ok := leadsCount[list.ListNumber]
-		if ok {
-			totalLeadsAvailable += count
-		}
+		plan.available += plan.leadsCount[list.ListNumber]
 	}
 
-	if totalLeadsAvailable == 0 {
-		log.Printf("No dialable lead available for campaign %s", campaign.ID)
-		return 0, nil
+	if plan.available == 0 {
+		return plan, nil
 	}
 
-	//inject lead proportionally across lists
-	totalInjected := 0
-	remainingToInject := 300
+	// read how much of each capped list's daily allowance is left
 	for _, list := range lists {
-		if remainingToInject <= 0 {
-			break
+		if list.MaxCallsPerDay <= 0 {
+			continue
 		}
 
-		listLeadCount, ok := leadsCount[list.ListNumber]
-		if !ok || listLeadCount == 0 {
+		injectedToday, err := redis.GetListDailyCount(ctx, campaign.WorkspaceID, list.ListNumber, now)
+		if err != nil {
+			log.Error("failed to get daily count", logging.ListNumber, list.ListNumber, "error", err)
 			continue
 		}
 
-		// Calculate proportional share for this list
-		proportion := float64(listLeadCount) / float64(totalLeadsAvailable)
-		listInjectCount := int(300 * proportion)
+		plan.dailyRemaining[list.ListNumber] = list.MaxCallsPerDay - injectedToday
+	}
+
+	// ask the rate controller how many leads the next window needs
+	plan.budget = config.GetDefaultInjectCap()
+	if calculate != nil {
+		plan.rate, err = calculate(ctx, campaign)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate injection rate: %v", err)
+		}
+		plan.budget = plan.rate.InjectCount
+	}
+
+	if plan.budget > 0 {
+		plan.allocations = allocateLeads(lists, plan.leadsCount, plan.dailyRemaining, plan.budget, now)
+	}
+
+	return plan, nil
+}
+
+// InjectLeadsFromList injects leads from list to queue system
+func (qm *QueueManager) InjectLeadsFromList(ctx context.Context, campaign db.Campaign, list db.List, listInjectCount int) (injected int, err error) {
+	ctx, span := tracer.Start(ctx, "hopper.inject_list", trace.WithAttributes(
+		attribute.String(logging.WorkspaceID, campaign.WorkspaceID),
+		attribute.String(logging.CampaignID, campaign.ID),
+		attribute.String(logging.ListNumber, list.ListNumber),
+		attribute.Int("requested", listInjectCount),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("injected", injected))
+		tracing.End(span, err)
+	}()
+
+	log := logging.FromContext(ctx, logger).With(logging.WorkspaceID, campaign.WorkspaceID, logging.CampaignID, campaign.ID, logging.ListNumber, list.ListNumber)
+
+	now := time.Now()
+	selections, err := qm.selectLeads(ctx, campaign, list.ListNumber, listInjectCount, now)
+	if err != nil {
+		return 0, err
+	}
 
-		// Ensure we don't exceed remaining capacity
-		if listInjectCount > remainingToInject {
-			listInjectCount = remainingToInject
+	var events []db.AuditEvent
+	defer func() { recordAudit(ctx, events...) }()
+
+	for _, selection := range selections {
+		lead := selection.lead
+		event := db.AuditEvent{
+			WorkspaceID: campaign.WorkspaceID,
+			At:          now,
+			CampaignID:  campaign.ID,
+			ListNumber:  list.ListNumber,
+			LeadID:      lead.LeadID,
 		}
 
-		// Ensure minimum of 1 if there are leads and capacity
-		if listInjectCount < 1 && listLeadCount > 0 && remainingToInject > 0 {
-			listInjectCount = 1
+		// a lead outside its window stays dialable for a later cycle
+		if selection.skipped == SkipOutsideWindow {
+			event.Type, event.Reason = db.AuditSkipped, db.AuditReasonOutsideWindow
+			events = append(events, event)
+			continue
 		}
 
-		if listInjectCount > 0 {
-			// injectleadfrom list
-			injected, err := qm.InjectLeadsFromList(campaign, list, listInjectCount)
-			if err != nil {
-				log.Printf("failed to inject leads from list %s", list.ListNumber)
-				continue
+		// mark the lead as taken before it becomes visible to dialers, a lead
+		// that cannot be dialed stays taken with a terminal status so it is
+		// neither picked every cycle nor reconciled back
+		if err := db.UpdateLeadDialStatus(ctx, campaign.WorkspaceID, list.ListNumber, lead.LeadID, false); err != nil {
+			log.Error("failed to mark lead as non dialable", logging.LeadID, lead.LeadID, "error", err)
+			continue
+		}
+
+		if selection.skipped == SkipBadPhone {
+			log.Warn("skipped lead with invalid phone number", logging.LeadID, lead.LeadID)
+			if err := db.UpdateLeadStatus(campaign.WorkspaceID, list.ListNumber, lead.LeadID, db.LeadStatusBadPhone); err != nil {
+				log.Error("failed to mark lead as bad phone", logging.LeadID, lead.LeadID, "error", err)
 			}
+			event.Type, event.Reason = db.AuditSkipped, db.AuditReasonBadPhone
+			events = append(events, event)
+			continue
+		}
 
-			totalInjected += injected
-			remainingToInject += injected
+		queuedLead := selection.queued
+		if err := redis.QueueLead(ctx, campaign.WorkspaceID, queuedLead); err != nil {
+			log.Error("failed to queue lead", logging.LeadID, lead.LeadID, "error", err)
+			// give the lead back so the next cycle can pick it up
+			if err := db.UpdateLeadDialStatus(ctx, campaign.WorkspaceID, list.ListNumber, lead.LeadID, true); err != nil {
+				log.Error("failed to reset lead to dialable", logging.LeadID, lead.LeadID, "error", err)
+			}
+			continue
 		}
+
+		event.Type, event.Detail = db.AuditInjected, "expires at "+queuedLead.ExpiresAt.Format(time.RFC3339)
+		events = append(events, event)
+		injected++
 	}
 
-	return totalInjected, nil
+	if injected > 0 {
+		metrics.LeadsInjected.WithLabelValues(campaign.WorkspaceID, campaign.ID, list.ListNumber).Add(float64(injected))
+
+		if _, err := redis.IncrementListDailyCount(ctx, campaign.WorkspaceID, list.ListNumber, time.Now(), injected); err != nil {
+			log.Error("failed to update daily count", "error", err)
+		}
+	}
+
+	log.Info("injected leads", "injected", injected, "requested", listInjectCount)
+	return injected, nil
 }
 
-// InjectLeadsFromList injects leads from list to queue system
-func (qm *QueueManager) InjectLeadsFromList(campaign db.Campaign, list db.List, listInjectCount int) (int, error) {
-	return 0, nil
+// leadSelection is what a cycle does with a dialable lead it picked: queue
+// it as queued, or skip it for a reason
+type leadSelection struct {
+	lead    db.ListData
+	queued  redis.QueuedLead
+	skipped SkipReason
+}
+
+// selectLeads reads up to count dialable leads of a list and decides what a
+// cycle does with each. A lead whose dialing window is already closed in its
+// local time would expire as soon as queued, it is skipped. Injection and the
+// dry run both select through it so the dry run reports what a cycle does.
+func (qm *QueueManager) selectLeads(ctx context.Context, campaign db.Campaign, listNumber string, count int, now time.Time) ([]leadSelection, error) {
+	leads, err := db.GetDialableLeads(ctx, campaign.WorkspaceID, listNumber, count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dialable leads for list %s: %v", listNumber, err)
+	}
+
+	ttl := config.GetQueuedLeadTTL()
+	selections := make([]leadSelection, 0, len(leads))
+	for _, lead := range leads {
+		selection := leadSelection{lead: lead}
+		expiresAt := qm.leadExpiry(campaign, lead.ZipCode, now, ttl)
+		switch {
+		case !validPhoneNumber(lead.PhoneNumber):
+			selection.skipped = SkipBadPhone
+		case !expiresAt.After(now):
+			selection.skipped = SkipOutsideWindow
+		default:
+			selection.queued = newQueuedLead(campaign.ID, lead, now)
+			selection.queued.ExpiresAt = expiresAt
+		}
+
+		selections = append(selections, selection)
+	}
+
+	return selections, nil
+}
+
+// DialNow pushes a lead in the dial now lane, ahead of every other lead of
+// its workspace. The list must belong to the campaign, and a lead already
+// queued or with a terminal status is not queued again. A dialable lead is
+// marked as taken so the hopper does not inject it a second time. The lead
+// expires like an injected one.
+func (qm *QueueManager) DialNow(workspaceID, campaignID, listNumber, leadID string) error {
+	ctx := context.Background()
+	campaign, err := workspaceCampaign(workspaceID, campaignID, listNumber)
+	if err != nil {
+		return err
+	}
+
+	lead, err := db.GetLeadByID(ctx, workspaceID, listNumber, leadID)
+	if err != nil {
+		return fmt.Errorf("failed to get lead %s: %v", leadID, err)
+	}
+
+	if db.IsTerminalLeadStatus(lead.CallStatus) {
+		return fmt.Errorf("lead %s has call status %s: %w", leadID, lead.CallStatus, ErrLeadNotDialable)
+	}
+
+	queued, err := redis.GetQueuedLeadIDs(workspaceID)
+	if err != nil {
+		return fmt.Errorf("failed to get queued leads: %v", err)
+	}
+
+	if queued[leadID] {
+		return fmt.Errorf("lead %s is already queued: %w", leadID, ErrLeadNotDialable)
+	}
+
+	now := time.Now()
+	queuedLead := newQueuedLead(campaignID, *lead, now)
+	queuedLead.ExpiresAt = qm.leadExpiry(*campaign, lead.ZipCode, now, config.GetQueuedLeadTTL())
+	if !queuedLead.ExpiresAt.After(now) {
+		return fmt.Errorf("lead %s: %w", leadID, ErrOutsideDialWindow)
+	}
+
+	if lead.Dialable {
+		if err := db.UpdateLeadDialStatus(ctx, workspaceID, listNumber, leadID, false); err != nil {
+			return fmt.Errorf("failed to mark lead %s as non dialable: %v", leadID, err)
+		}
+	}
+
+	if err := redis.QueueLeadInLane(ctx, workspaceID, redis.LaneDialNow, queuedLead); err != nil {
+		return fmt.Errorf("failed to queue lead %s: %v", leadID, err)
+	}
+
+	logger.Info("queued lead to dial now", logging.WorkspaceID, workspaceID, logging.CampaignID, campaignID, logging.ListNumber, listNumber, logging.LeadID, leadID)
+	return nil
+}
+
+// workspaceCampaign returns a campaign of a workspace once it checked the
+// list belongs to it
+func workspaceCampaign(workspaceID, campaignID, listNumber string) (*db.Campaign, error) {
+	campaigns, err := db.GetCampaignsByWorkspace(workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaigns: %v", err)
+	}
+
+	var campaign *db.Campaign
+	for i := range campaigns {
+		if campaigns[i].ID == campaignID {
+			campaign = &campaigns[i]
+			break
+		}
+	}
+
+	if campaign == nil {
+		return nil, fmt.Errorf("campaign %s: %w", campaignID, db.ErrCampaignNotFound)
+	}
+
+	lists, err := db.GetListsByWorkspace(workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lists: %v", err)
+	}
+
+	for _, list := range lists {
+		if list.ListNumber == listNumber && list.CampaignID == campaignID {
+			return campaign, nil
+		}
+	}
+
+	return nil, fmt.Errorf("list %s, campaign %s: %w", listNumber, campaignID, ErrListNotInCampaign)
+}
+
+// RetryLead queues a lead again in the retry lane after a failed attempt, ahead
+// of the regular injections. A lead that keeps failing is moved to the dead
+// letter queue of its workspace with the reason of the last failure.
+func (qm *QueueManager) RetryLead(lead redis.QueuedLead, reason string) error {
+	lead.CallAttempts++
+	lead.FailedAttempts++
+	lead.QueuedAt = time.Now()
+
+	if lead.FailedAttempts >= config.GetMaxDialFailures() {
+		if err := redis.DeadLetterLead(lead.WorkspaceID, lead, reason); err != nil {
+			return fmt.Errorf("failed to dead letter lead %s: %v", lead.LeadID, err)
+		}
+
+		logger.Warn("lead moved to dead letter queue", logging.WorkspaceID, lead.WorkspaceID, logging.CampaignID, lead.CampaignID,
+			logging.ListNumber, lead.ListNumber, logging.LeadID, lead.LeadID, "failed_attempts", lead.FailedAttempts, "reason", reason)
+		return nil
+	}
+
+	if err := redis.QueueLeadInLane(context.Background(), lead.WorkspaceID, redis.LaneRetry, lead); err != nil {
+		return fmt.Errorf("failed to queue retry for lead %s: %v", lead.LeadID, err)
+	}
+
+	return nil
+}
+
+// newQueuedLead builds the queue entry of a lead
+func newQueuedLead(campaignID string, lead db.ListData, now time.Time) redis.QueuedLead {
+	return redis.QueuedLead{
+		LeadID:       lead.LeadID,
+		ListNumber:   lead.ListNumber,
+		WorkspaceID:  lead.WorkspaceID,
+		CampaignID:   campaignID,
+		PhoneNumber:  lead.PhoneNumber,
+		FirstName:    lead.FirstName,
+		LastName:     lead.LastName,
+		ZipCode:      lead.ZipCode,
+		ExtraData:    lead.ExtraData,
+		QueuedAt:     now,
+		CallAttempts: lead.CallCount,
+		CallStatus:   lead.CallStatus,
+	}
+}
+
+// leadExpiry returns when a lead queued at now stops being worth dialing:
+// after ttl, or when the campaign dialing window closes in the lead's local
+// time, whichever comes first
+func (qm *QueueManager) leadExpiry(campaign db.Campaign, zipCode string, now time.Time, ttl time.Duration) time.Time {
+	expiresAt := now.Add(ttl)
+	if qm.zipCodeCache == nil {
+		return expiresAt
+	}
+
+	localNow, err := tz.GetLocalTimeAt(qm.zipCodeCache, zipCode, now)
+	if err != nil {
+		return expiresAt
+	}
+
+	windowClose := dialWindowClose(localNow, campaign.DialEndHour)
+	if windowClose.Before(expiresAt) {
+		return windowClose
+	}
+
+	return expiresAt
+}
+
+// dialWindowClose returns the end of the dialing window on the local day of
+// localNow, the window runs through the whole dial end hour
+func dialWindowClose(localNow time.Time, dialEndHour int) time.Time {
+	year, month, day := localNow.Date()
+	return time.Date(year, month, day, dialEndHour+1, 0, 0, 0, localNow.Location())
+}
+
+// validPhoneNumber reports whether a phone number has between 10 and 15
+// digits, formatting characters aside
+func validPhoneNumber(phoneNumber string) bool {
+	digits := 0
+	for _, r := range phoneNumber {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case strings.ContainsRune("+-.() ", r):
+		default:
+			return false
+		}
+	}
+
+	return digits >= 10 && digits <= 15
 }
 
 func contains(currentWeekDay time.Weekday, days []int) bool {
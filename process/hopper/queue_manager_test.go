@@ -0,0 +1,50 @@
+package hopper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/tz"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDialWindowClose tests the window closes at the end of the dial end hour
+func TestDialWindowClose(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	localNow := time.Date(2025, 3, 10, 14, 30, 0, 0, loc)
+
+	result := dialWindowClose(localNow, 17)
+	assert.Equal(t, time.Date(2025, 3, 10, 18, 0, 0, 0, loc), result)
+}
+
+// TestLeadExpiry tests queued leads expire at the earliest of ttl and window close
+func TestLeadExpiry(t *testing.T) {
+	cache := tz.NewZipCodeCache()
+	cache.Set("10001", &tz.ZipCodeInfo{ZipCode: "10001", TimeZone: "America/New_York"})
+
+	qm := NewQueueManager(nil, cache)
+	campaign := db.Campaign{DialEndHour: 17}
+
+	// 21:30 UTC is 17:30 in New York, the window closes in 30 minutes
+	now := time.Date(2025, 3, 10, 21, 30, 0, 0, time.UTC)
+	assert.Equal(t, now.Add(30*time.Minute), qm.leadExpiry(campaign, "10001", now, time.Hour).UTC())
+
+	// ttl first
+	assert.Equal(t, now.Add(10*time.Minute), qm.leadExpiry(campaign, "10001", now, 10*time.Minute).UTC())
+
+	// unknown zip code falls back to ttl
+	assert.Equal(t, now.Add(time.Hour), qm.leadExpiry(campaign, "99999", now, time.Hour).UTC())
+}
+
+// TestValidPhoneNumber tests phone numbers need 10 to 15 digits and no letters
+func TestValidPhoneNumber(t *testing.T) {
+	assert.True(t, validPhoneNumber("2125551234"))
+	assert.True(t, validPhoneNumber("+1 (212) 555-1234"))
+	assert.True(t, validPhoneNumber("212.555.1234"))
+
+	assert.False(t, validPhoneNumber(""))
+	assert.False(t, validPhoneNumber("555-1234"))
+	assert.False(t, validPhoneNumber("212555123x"))
+	assert.False(t, validPhoneNumber("1234567890123456"))
+}
@@ -0,0 +1,39 @@
+package hopper
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// CycleStatus describes the last hopper cycle
+type CycleStatus struct {
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Workspaces      int       `json:"workspaces"`
+	Processed       int       `json:"processed"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// lastCycle is the status of the last finished cycle, nil until one finishes
+var lastCycle atomic.Pointer[CycleStatus]
+
+// LastCycle returns the status of the last finished hopper cycle, nil when no
+// cycle has run yet
+func LastCycle() *CycleStatus {
+	return lastCycle.Load()
+}
+
+// recordCycle stores the status of a finished cycle
+func recordCycle(start time.Time, workspaces, processed int, err error) {
+	status := &CycleStatus{
+		StartedAt:       start,
+		DurationSeconds: time.Since(start).Seconds(),
+		Workspaces:      workspaces,
+		Processed:       processed,
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	lastCycle.Store(status)
+}
@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Attribute keys shared by every package
+const (
+	CycleID     = "cycle_id"
+	WorkspaceID = "workspace_id"
+	CampaignID  = "campaign_id"
+	ListNumber  = "list_number"
+	LeadID      = "lead_id"
+	Component   = "component"
+)
+
+type (
+	contextKey struct{}
+	cycleIDKey struct{}
+)
+
+// Setup makes a logger with the given level and format, text or json, the
+// default logger of the process. The standard log package writes through it.
+func Setup(w io.Writer, level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+
+	options := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	if strings.EqualFold(format, "json") {
+		handler = slog.NewJSONHandler(w, options)
+	} else {
+		handler = slog.NewTextHandler(w, options)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// For returns a logger tagged with a component, e.g. hopper. It writes
+// through whatever the default logger is when it logs, so packages can keep
+// it in a variable created before Setup runs.
+func For(component string) *slog.Logger {
+	return slog.New(lazyHandler{}).With(Component, component)
+}
+
+// lazyHandler replays its attributes and groups on the default handler
+type lazyHandler struct {
+	ops []func(slog.Handler) slog.Handler
+}
+
+func (h lazyHandler) resolve() slog.Handler {
+	handler := slog.Default().Handler()
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	return handler
+}
+
+func (h lazyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+func (h lazyHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.resolve().Handle(ctx, record)
+}
+
+func (h lazyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h lazyHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h lazyHandler) with(op func(slog.Handler) slog.Handler) lazyHandler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return lazyHandler{ops: append(ops, op)}
+}
+
+// WithLogger returns a context carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or fallback when there is none
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return fallback
+}
+
+// NewCycleID returns a random id tying together the logs of a hopper cycle
+func NewCycleID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithCycleID returns a context carrying the id of the hopper cycle it runs in
+func WithCycleID(ctx context.Context, cycleID string) context.Context {
+	return context.WithValue(ctx, cycleIDKey{}, cycleID)
+}
+
+// CycleIDFromContext returns the hopper cycle id carried by ctx, empty outside a cycle
+func CycleIDFromContext(ctx context.Context) string {
+	cycleID, _ := ctx.Value(cycleIDKey{}).(string)
+	return cycleID
+}
@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSetupJSON tests logs are written as json above the configured level
+func TestSetupJSON(t *testing.T) {
+	previous := slog.Default()
+	defer slog.SetDefault(previous)
+
+	// loggers created before Setup write through the new default
+	logger := For("hopper").With(WorkspaceID, "ws1")
+
+	var buf bytes.Buffer
+	err := Setup(&buf, "warn", "json")
+	assert.NoError(t, err)
+
+	logger.Info("ignored")
+	logger.Warn("queue full")
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "queue full", entry["msg"])
+	assert.Equal(t, "hopper", entry[Component])
+	assert.Equal(t, "ws1", entry[WorkspaceID])
+}
+
+// TestSetupInvalidLevel tests an unknown level is rejected
+func TestSetupInvalidLevel(t *testing.T) {
+	err := Setup(&bytes.Buffer{}, "loud", "text")
+	assert.Error(t, err)
+}
+
+// TestFromContext tests the logger carried by a context is returned
+func TestFromContext(t *testing.T) {
+	fallback := slog.Default()
+	logger := fallback.With(CycleID, "c1")
+
+	assert.Equal(t, fallback, FromContext(context.Background(), fallback))
+	assert.Equal(t, logger, FromContext(WithLogger(context.Background(), logger), fallback))
+}
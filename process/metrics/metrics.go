@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "process"
+
+var (
+	// LeadsInjected counts the leads queued by the hopper
+	LeadsInjected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leads_injected_total",
+		Help:      "Leads injected into workspace queues.",
+	}, []string{"workspace", "campaign", "list"})
+
+	// DeadLettersTrimmed counts the dead letters dropped from a full dead
+	// letter queue
+	DeadLettersTrimmed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dead_letters_trimmed_total",
+		Help:      "Dead letters dropped from a full workspace dead letter queue.",
+	}, []string{"workspace"})
+
+	// CycleDuration measures a hopper cycle across every workspace
+	CycleDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "hopper_cycle_duration_seconds",
+		Help:      "Duration of a hopper cycle across every workspace.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	})
+
+	// QueueDepth is the number of leads waiting in a workspace queue, read
+	// from redis by a collector when scraped
+	QueueDepth = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "queue_depth"),
+		"Leads waiting in a workspace queue, across all lanes.", []string{"workspace"}, nil)
+
+	// CallsInProgress is the number of live call leases of a workspace, read
+	// from redis by a collector when scraped
+	CallsInProgress = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "calls_in_progress"),
+		"Calls in progress in a workspace.", []string{"workspace"}, nil)
+
+	// AvailableCapacity is the number of leads the rate controller allows for
+	// the next window of a campaign
+	AvailableCapacity = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "rate_available_capacity",
+		Help:      "Leads the rate controller allows a campaign to inject for the next window.",
+	}, []string{"workspace", "campaign"})
+
+	// CassandraDuration measures cassandra queries and batches
+	CassandraDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cassandra_op_duration_seconds",
+		Help:      "Duration of cassandra queries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	// CassandraErrors counts failed cassandra queries and batches
+	CassandraErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cassandra_op_errors_total",
+		Help:      "Failed cassandra queries.",
+	}, []string{"operation"})
+
+	// RedisDuration measures redis commands and pipelines
+	RedisDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redis_op_duration_seconds",
+		Help:      "Duration of redis commands.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"command"})
+
+	// RedisErrors counts failed redis commands and pipelines
+	RedisErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_op_errors_total",
+		Help:      "Failed redis commands.",
+	}, []string{"command"})
+)
+
+// Handler serves the metrics in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -2,24 +2,85 @@ package orchestrator
 
 import (
 	"context"
-	"log"
+	"time"
 
+	"github.com/nico-phil/process/cleanup"
+	"github.com/nico-phil/process/config"
 	"github.com/nico-phil/process/hopper"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/ratelimit"
+	"github.com/nico-phil/process/redis"
+	"github.com/nico-phil/process/tz"
 )
 
+var logger = logging.For("orchestrator")
+
 // ProcessOrchestrator manages the main process scheduling and coordination
 type ProcessOrchestrator struct {
-	queueManager *hopper.QueueManager
+	queueManager   *hopper.QueueManager
+	cleanupService *cleanup.Service
 }
 
 // New create a process orchestrator
-func New() *ProcessOrchestrator {
-	return &ProcessOrchestrator{}
+func New(zipCodeCache *tz.ZipCodeCache) *ProcessOrchestrator {
+	return &ProcessOrchestrator{
+		queueManager:   hopper.NewQueueManager(ratelimit.NewRateController(), zipCodeCache),
+		cleanupService: cleanup.NewService(),
+	}
 }
 
 // Start starts orchestration process
 func (po *ProcessOrchestrator) Start() {
-	log.Printf("starting orchestrator")
+	logger.Debug("starting cycle")
+
+	// register workspaces queued before the active workspace set existed
+	if _, err := redis.ScanQueuedWorkspaces(); err != nil {
+		logger.Error("workspace scan failed", "error", err)
+	}
+
+	// reclaim leads of deactivated campaigns and lists before injecting new ones
+	if _, err := po.cleanupService.Run(context.Background()); err != nil {
+		logger.Error("cleanup failed", "error", err)
+	}
+
+	// give expired leads back to cassandra so they are picked up in a valid window
+	if _, err := po.cleanupService.SweepExpiredLeads(context.Background()); err != nil {
+		logger.Error("expired lead sweep failed", "error", err)
+	}
+
 	po.queueManager.ProcessAllWorkspacesWithContext(context.Background())
 	// this function will get 5 min of data in the database and put it in redis
 }
+
+// Run starts a cycle right away, then one every tick interval until ctx is
+// done. The interval is read after every cycle so a reloaded value applies.
+func (po *ProcessOrchestrator) Run(ctx context.Context) {
+	for {
+		po.Start()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(config.GetTickInterval()):
+		}
+	}
+}
+
+// StartReconciler reconciles redis with cassandra right away, then every
+// interval until ctx is done
+func (po *ProcessOrchestrator) StartReconciler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := po.cleanupService.Reconcile(ctx); err != nil {
+			logger.Error("reconciliation failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
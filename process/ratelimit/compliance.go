@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/redis"
+)
+
+const (
+	// ComplianceOK means the campaign abandon rate is comfortably under the threshold
+	ComplianceOK = "ok"
+	// ComplianceThrottled means pacing is reduced as the abandon rate nears the threshold
+	ComplianceThrottled = "throttled"
+	// CompliancePaused means no leads are injected until the abandon rate drops
+	CompliancePaused = "paused"
+
+	// complianceWarnRatio is the share of the threshold at which throttling starts
+	complianceWarnRatio = 0.8
+	// minComplianceSamples is the number of answered calls needed before the
+	// measured abandon rate is acted on
+	minComplianceSamples = 20
+)
+
+// ComplianceState is the abandon rate of a campaign over the compliance period
+type ComplianceState struct {
+	CampaignID  string    `json:"campaign_id"`
+	Answered    int       `json:"answered"`
+	Abandoned   int       `json:"abandoned"`
+	AbandonRate float64   `json:"abandon_rate"`
+	Threshold   float64   `json:"threshold"`
+	PeriodDays  int       `json:"period_days"`
+	State       string    `json:"state"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RecordDisposition records the outcome of a campaign call for the abandon
+// rate. Abandoned calls are answered calls that no agent picked up.
+func (rc *RateController) RecordDisposition(campaignID string, answered, abandoned bool) error {
+	retention := time.Duration(rc.abandonPeriodDays+1) * 24 * time.Hour
+	if err := redis.RecordCampaignDisposition(campaignID, answered || abandoned, abandoned, retention); err != nil {
+		return fmt.Errorf("failed to record disposition: %v", err)
+	}
+
+	return nil
+}
+
+// CheckAbandonCompliance measures the campaign abandon rate over the
+// compliance period, stores the resulting state and returns it
+func (rc *RateController) CheckAbandonCompliance(ctx context.Context, campaignID string) (*ComplianceState, error) {
+	state, err := rc.EvaluateAbandonCompliance(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal compliance state: %v", err)
+	}
+
+	if err := redis.SetCampaignCompliance(ctx, campaignID, payload); err != nil {
+		logger.Error("failed to store compliance state", logging.CampaignID, campaignID, "error", err)
+	}
+
+	if state.State != ComplianceOK {
+		logger.Warn("campaign abandon rate near threshold", logging.CampaignID, campaignID,
+			"abandon_rate", state.AbandonRate, "threshold", state.Threshold, "state", state.State)
+	}
+
+	return state, nil
+}
+
+// EvaluateAbandonCompliance measures the campaign abandon rate over the
+// compliance period without storing the resulting state
+func (rc *RateController) EvaluateAbandonCompliance(ctx context.Context, campaignID string) (*ComplianceState, error) {
+	answered, abandoned, err := redis.GetCampaignDispositions(ctx, campaignID, rc.abandonPeriodDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check abandon compliance for campaign %s: %v", campaignID, err)
+	}
+
+	state := evaluateCompliance(answered, abandoned, rc.abandonThreshold)
+	state.CampaignID = campaignID
+	state.PeriodDays = rc.abandonPeriodDays
+	state.UpdatedAt = time.Now()
+
+	return state, nil
+}
+
+// GetComplianceState returns the last stored compliance state of a campaign,
+// computing it when nothing has been stored yet
+func (rc *RateController) GetComplianceState(ctx context.Context, campaignID string) (*ComplianceState, error) {
+	payload, err := redis.GetCampaignCompliance(campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload == nil {
+		return rc.CheckAbandonCompliance(ctx, campaignID)
+	}
+
+	var state ComplianceState
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal compliance state: %v", err)
+	}
+
+	return &state, nil
+}
+
+// evaluateCompliance computes the abandon rate and the resulting state
+func evaluateCompliance(answered, abandoned int, threshold float64) *ComplianceState {
+	state := &ComplianceState{
+		Answered:  answered,
+		Abandoned: abandoned,
+		Threshold: threshold,
+		State:     ComplianceOK,
+	}
+
+	if answered > 0 {
+		state.AbandonRate = float64(abandoned) / float64(answered)
+	}
+
+	if answered < minComplianceSamples {
+		return state
+	}
+
+	switch {
+	case state.AbandonRate >= threshold:
+		state.State = CompliancePaused
+	case state.AbandonRate >= threshold*complianceWarnRatio:
+		state.State = ComplianceThrottled
+	}
+
+	return state
+}
+
+// applyCompliance scales down an inject count as the abandon rate moves from
+// the warning level to the threshold, and stops injection past it
+func applyCompliance(injectCount int, state *ComplianceState) int {
+	switch state.State {
+	case CompliancePaused:
+		return 0
+	case ComplianceThrottled:
+		warn := state.Threshold * complianceWarnRatio
+		factor := (state.Threshold - state.AbandonRate) / (state.Threshold - warn)
+		return int(float64(injectCount) * factor)
+	default:
+		return injectCount
+	}
+}
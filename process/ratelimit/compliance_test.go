@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEvaluateCompliance tests the compliance state for different abandon rates
+func TestEvaluateCompliance(t *testing.T) {
+	cases := []struct {
+		name      string
+		answered  int
+		abandoned int
+		expected  string
+	}{
+		{name: "no calls", answered: 0, abandoned: 0, expected: ComplianceOK},
+		{name: "too few samples", answered: 10, abandoned: 5, expected: ComplianceOK},
+		{name: "under warning level", answered: 1000, abandoned: 10, expected: ComplianceOK},
+		{name: "near threshold", answered: 1000, abandoned: 27, expected: ComplianceThrottled},
+		{name: "over threshold", answered: 1000, abandoned: 35, expected: CompliancePaused},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			state := evaluateCompliance(c.answered, c.abandoned, 0.03)
+			assert.Equal(t, c.expected, state.State)
+		})
+	}
+}
+
+// TestApplyCompliance tests inject counts are reduced as the abandon rate rises
+func TestApplyCompliance(t *testing.T) {
+	ok := &ComplianceState{State: ComplianceOK, Threshold: 0.03, AbandonRate: 0.01}
+	assert.Equal(t, 100, applyCompliance(100, ok))
+
+	throttled := &ComplianceState{State: ComplianceThrottled, Threshold: 0.03, AbandonRate: 0.027}
+	assert.Equal(t, 50, applyCompliance(100, throttled))
+
+	paused := &ComplianceState{State: CompliancePaused, Threshold: 0.03, AbandonRate: 0.04}
+	assert.Equal(t, 0, applyCompliance(100, paused))
+}
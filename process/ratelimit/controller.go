@@ -1,21 +1,35 @@
 package ratelimit
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"time"
 
+	"github.com/nico-phil/process/config"
 	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/metrics"
 	"github.com/nico-phil/process/redis"
 )
 
+var logger = logging.For("ratelimit")
+
 // RateController manages rate limiting for campaigns
 type RateController struct {
+	pacingMode        string
+	targetAbandonRate float64
+	abandonThreshold  float64
+	abandonPeriodDays int
 }
 
 // NewRateController return a new rate contoller
 func NewRateController() *RateController {
-	return &RateController{}
+	return &RateController{
+		pacingMode:        config.GetPacingMode(),
+		targetAbandonRate: config.GetTargetAbandonRate(),
+		abandonThreshold:  config.GetAbandonRateThreshold(),
+		abandonPeriodDays: config.GetAbandonPeriodDays(),
+	}
 }
 
 // RateCalculation contains the calculated rate information
@@ -27,33 +41,86 @@ type RateCalculation struct {
 	CalculatedRate         int
 	QueueDepth             int
 	TimeWindow             time.Duration
+	InjectCount            int
+	DialRatio              float64
+	ComplianceState        string
+}
+
+// CalculateInjection calculates how many leads to inject for the next window
+// using the configured pacing mode, reduced by the abandon rate guard
+func (rc *RateController) CalculateInjection(ctx context.Context, campaign db.Campaign) (*RateCalculation, error) {
+	calculation, err := rc.calculatePacing(ctx, campaign)
+	if err != nil {
+		return nil, err
+	}
+
+	compliance, err := rc.CheckAbandonCompliance(ctx, campaign.ID)
+	if err != nil {
+		logger.Error("failed to check abandon compliance", logging.WorkspaceID, campaign.WorkspaceID, logging.CampaignID, campaign.ID, "error", err)
+		return nil, fmt.Errorf("failed to check abandon compliance for campaign %s", campaign.ID)
+	}
+
+	calculation.InjectCount = applyCompliance(calculation.InjectCount, compliance)
+	calculation.ComplianceState = compliance.State
+
+	metrics.AvailableCapacity.WithLabelValues(campaign.WorkspaceID, campaign.ID).Set(float64(calculation.InjectCount))
+
+	return calculation, nil
+}
+
+// PreviewInjection calculates the same injection as CalculateInjection
+// without storing the compliance state or updating the capacity metric
+func (rc *RateController) PreviewInjection(ctx context.Context, campaign db.Campaign) (*RateCalculation, error) {
+	calculation, err := rc.calculatePacing(ctx, campaign)
+	if err != nil {
+		return nil, err
+	}
+
+	compliance, err := rc.EvaluateAbandonCompliance(ctx, campaign.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	calculation.InjectCount = applyCompliance(calculation.InjectCount, compliance)
+	calculation.ComplianceState = compliance.State
+
+	return calculation, nil
+}
+
+// calculatePacing calculates the injection of the configured pacing mode
+func (rc *RateController) calculatePacing(ctx context.Context, campaign db.Campaign) (*RateCalculation, error) {
+	if rc.pacingMode == PacingAdaptive {
+		return rc.CalculateAdaptiveInjection(ctx, campaign)
+	}
+
+	return rc.CalculateInjectionRate(ctx, campaign)
 }
 
 // CalculateInjectionRate calculate how many leads to inject for the next 5 minutes
-func (rc *RateController) CalculateInjectionRate(campaign db.Campaign) (*RateCalculation, error) {
+func (rc *RateController) CalculateInjectionRate(ctx context.Context, campaign db.Campaign) (*RateCalculation, error) {
 
 	// get current calls in progres for this workspace
-	currentCalls, err := redis.GetCallCount(campaign.WorkspaceID)
+	currentCalls, err := redis.GetCallCount(ctx, campaign.WorkspaceID)
 	if err != nil {
-		log.Printf("failed to get current call count for workspace %s: %v", campaign.WorkspaceID, err)
+		logger.Error("failed to get current call count", logging.WorkspaceID, campaign.WorkspaceID, "error", err)
 		return nil, fmt.Errorf("failed to get current call count for workspace %s", campaign.WorkspaceID)
 	}
 
 	// Get current queue depth
-	queueLength, err := redis.GetQueueLength(campaign.WorkspaceID)
+	queueLength, err := redis.GetQueueLength(ctx, campaign.WorkspaceID)
 	if err != nil {
-		log.Printf("failed to get queue length for workspace %s", campaign.WorkspaceID)
+		logger.Error("failed to get queue length", logging.WorkspaceID, campaign.WorkspaceID, "error", err)
 		return nil, fmt.Errorf("failed to get queue length for workspace %s", campaign.WorkspaceID)
 	}
 
 	// calculate available capacity
 	maxRate := campaign.MaxRatePerMin
 	if maxRate <= 0 {
-		maxRate = 60 // 60 min
+		maxRate = config.GetDefaultMaxRatePerMin()
 	}
 
-	// for 5-minutes window, we want to inject enougth lead for the next 5 minutes
-	timeWindow := 5 * time.Minute
+	// inject enougth lead for the next window, 5 minutes by default
+	timeWindow := config.GetInjectionWindow()
 	windowMinutes := int(timeWindow.Minutes())
 
 	// total capacity for the time window
@@ -78,24 +145,26 @@ func (rc *RateController) CalculateInjectionRate(campaign db.Campaign) (*RateCal
 		CalculatedRate:         maxRate,
 		QueueDepth:             queueLength,
 		TimeWindow:             timeWindow,
+		InjectCount:            availableCapacity,
+		DialRatio:              1,
 	}
 
-	log.Printf("Rate calculation for campaign %s: max=%d/min, current=%d, queue=%d, calculated=%d for %v window",
-		campaign.ID, maxRate, currentCalls, queueLength, availableCapacity, timeWindow)
+	logger.Debug("static rate calculation", logging.WorkspaceID, campaign.WorkspaceID, logging.CampaignID, campaign.ID,
+		"max_rate", maxRate, "current_calls", currentCalls, "queue", queueLength, "inject", availableCapacity, "window", timeWindow)
 
 	return calculation, nil
 }
 
 // CanInjectLeads checks if we can inject more leads based on rate limits
-func (rc *RateController) CanInjectLeads(workspaceID string, maxRatePerMinute int) (bool, int, error) {
+func (rc *RateController) CanInjectLeads(ctx context.Context, workspaceID string, maxRatePerMinute int) (bool, int, error) {
 	// Get current calls in progress
-	currentCalls, err := redis.GetCallCount(workspaceID)
+	currentCalls, err := redis.GetCallCount(ctx, workspaceID)
 	if err != nil {
 		return false, 0, fmt.Errorf("failed to get current call count: %v", err)
 	}
 
 	// Get current queue depth
-	queueDepth, err := redis.GetQueueLength(workspaceID)
+	queueDepth, err := redis.GetQueueLength(ctx, workspaceID)
 	if err != nil {
 		return false, 0, fmt.Errorf("failed to get queue depth: %v", err)
 	}
@@ -114,30 +183,87 @@ func (rc *RateController) CanInjectLeads(workspaceID string, maxRatePerMinute in
 		availableCapacity = 0
 	}
 
-	log.Printf("Can inject check for workspace %s: current_load=%d, buffer_capacity=%d, can_inject=%v, available=%d",
-		workspaceID, currentLoad, bufferCapacity, canInject, availableCapacity)
+	logger.Debug("can inject check", logging.WorkspaceID, workspaceID,
+		"current_load", currentLoad, "buffer_capacity", bufferCapacity, "can_inject", canInject, "available", availableCapacity)
 
 	return canInject, availableCapacity, nil
 }
 
-// TrackCallStart tracks when a call starts (increment counter)
-func (rc *RateController) TrackCallStart(workspaceID string) error {
-	_, err := redis.IncrementCallCount(workspaceID)
+// PublishRateLimits caches the max rate of every campaign and the workspace
+// total in redis, where they are enforced as token buckets at dequeue time
+func (rc *RateController) PublishRateLimits(ctx context.Context, workspaceID string, campaigns []db.Campaign) error {
+	workspaceRate := 0
+	rates := make(map[string]int, len(campaigns))
+	for _, campaign := range campaigns {
+		maxRate := campaign.MaxRatePerMin
+		if maxRate <= 0 {
+			maxRate = config.GetDefaultMaxRatePerMin()
+		}
+
+		rates[campaign.ID] = maxRate
+		workspaceRate += maxRate
+	}
+
+	if err := redis.CacheCampaignRates(ctx, workspaceID, rates); err != nil {
+		return fmt.Errorf("failed to publish campaign rates for workspace %s: %v", workspaceID, err)
+	}
+
+	if err := redis.CacheWorkspaceRate(ctx, workspaceID, workspaceRate); err != nil {
+		return fmt.Errorf("failed to publish rate for workspace %s: %v", workspaceID, err)
+	}
+
+	return nil
+}
+
+// TrackCallStart tracks when a call starts by taking a lease on it
+func (rc *RateController) TrackCallStart(workspaceID, callID string) error {
+	err := redis.StartCallLease(workspaceID, callID, config.GetCallLeaseTTL())
 	if err != nil {
 		return fmt.Errorf("failed to track call start: %v", err)
 	}
 
-	log.Printf("[Campaign-aware posting service]: [%s]: Tracked call start for workspace %s", workspaceID, workspaceID)
+	logger.Debug("tracked call start", logging.WorkspaceID, workspaceID, "call_id", callID)
 	return nil
 }
 
-// TrackCallEnd tracks when a call ends (decrement counter)
-func (rc *RateController) TrackCallEnd(workspaceID string) error {
-	_, err := redis.DecrementCallCount(workspaceID)
+// TrackCallHeartbeat renews the lease of a long running call
+func (rc *RateController) TrackCallHeartbeat(workspaceID, callID string) error {
+	err := redis.RenewCallLease(workspaceID, callID, config.GetCallLeaseTTL())
 	if err != nil {
-		return fmt.Errorf("failed to track call end: %v", err)
+		return fmt.Errorf("failed to track call heartbeat: %w", err)
 	}
 
-	log.Printf("Tracked call end for workspace %s", workspaceID)
 	return nil
 }
+
+// TrackCallOutcome feeds the result of a finished call to the adaptive pacing stats
+func (rc *RateController) TrackCallOutcome(workspaceID string, answered bool, handleTime time.Duration) error {
+	err := redis.RecordCallOutcome(workspaceID, answered, handleTime)
+	if err != nil {
+		return fmt.Errorf("failed to track call outcome: %v", err)
+	}
+
+	return nil
+}
+
+// TrackAvailableAgents records how many agents of a workspace are ready for a call
+func (rc *RateController) TrackAvailableAgents(workspaceID string, agents int) error {
+	err := redis.SetAvailableAgents(workspaceID, agents)
+	if err != nil {
+		return fmt.Errorf("failed to track available agents: %v", err)
+	}
+
+	return nil
+}
+
+// TrackCallEnd tracks when a call ends by releasing its lease. It reports
+// false when the call had no lease left, so a repeated end is not counted.
+func (rc *RateController) TrackCallEnd(workspaceID, callID string) (bool, error) {
+	ended, err := redis.EndCallLease(workspaceID, callID)
+	if err != nil {
+		return false, fmt.Errorf("failed to track call end: %v", err)
+	}
+
+	logger.Debug("tracked call end", logging.WorkspaceID, workspaceID, "call_id", callID, "ended", ended)
+	return ended, nil
+}
@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/db"
+	"github.com/nico-phil/process/logging"
+	"github.com/nico-phil/process/redis"
+)
+
+const (
+	// PacingStatic injects MaxRatePerMin leads per minute of the window
+	PacingStatic = "static"
+	// PacingAdaptive injects enough leads to keep the available agents busy
+	PacingAdaptive = "adaptive"
+
+	// maxDialRatio bounds how many lines we dial per available agent
+	maxDialRatio = 3.0
+	// minPacingSamples is the number of dialed calls needed before the
+	// measured answer rate is trusted
+	minPacingSamples = 20
+	// defaultHandleTime is used until answered calls report a handle time
+	defaultHandleTime = 3 * time.Minute
+)
+
+// CalculateDialRatio returns how many lines to dial per available agent so
+// that, at the given answer rate, the expected share of answered calls
+// without a free agent stays under targetAbandonRate. Dialing r lines per
+// agent yields r*answerRate answered calls, the surplus over one agent is
+// abandoned, so r*answerRate must stay below 1/(1-targetAbandonRate).
+func CalculateDialRatio(answerRate, targetAbandonRate float64) float64 {
+	if answerRate <= 0 {
+		return maxDialRatio
+	}
+
+	if targetAbandonRate < 0 {
+		targetAbandonRate = 0
+	}
+
+	if targetAbandonRate >= 1 {
+		return maxDialRatio
+	}
+
+	ratio := 1 / (answerRate * (1 - targetAbandonRate))
+	return math.Max(1, math.Min(maxDialRatio, ratio))
+}
+
+// CalculateAdaptiveInjection calculates how many leads to inject for the next
+// window from the workspace answer rate, average handle time and available
+// agents. MaxRatePerMin stays the ceiling. Until enough calls have been
+// measured it falls back to CalculateInjectionRate.
+func (rc *RateController) CalculateAdaptiveInjection(ctx context.Context, campaign db.Campaign) (*RateCalculation, error) {
+	timeWindow := config.GetInjectionWindow()
+
+	stats, err := redis.GetPacingStats(ctx, campaign.WorkspaceID, timeWindow)
+	if err != nil {
+		logger.Error("failed to get pacing stats", logging.WorkspaceID, campaign.WorkspaceID, "error", err)
+		return nil, fmt.Errorf("failed to get pacing stats for workspace %s", campaign.WorkspaceID)
+	}
+
+	if stats.Dialed < minPacingSamples {
+		logger.Debug("not enough pacing samples, using static pacing", logging.WorkspaceID, campaign.WorkspaceID, logging.CampaignID, campaign.ID, "dialed", stats.Dialed)
+		return rc.CalculateInjectionRate(ctx, campaign)
+	}
+
+	queueLength, err := redis.GetQueueLength(ctx, campaign.WorkspaceID)
+	if err != nil {
+		logger.Error("failed to get queue length", logging.WorkspaceID, campaign.WorkspaceID, "error", err)
+		return nil, fmt.Errorf("failed to get queue length for workspace %s", campaign.WorkspaceID)
+	}
+
+	currentCalls, err := redis.GetCallCount(ctx, campaign.WorkspaceID)
+	if err != nil {
+		logger.Error("failed to get current call count", logging.WorkspaceID, campaign.WorkspaceID, "error", err)
+		return nil, fmt.Errorf("failed to get current call count for workspace %s", campaign.WorkspaceID)
+	}
+
+	campaigns, err := redis.GetRatedCampaignCount(ctx, campaign.WorkspaceID)
+	if err != nil {
+		logger.Error("failed to get campaign count", logging.WorkspaceID, campaign.WorkspaceID, "error", err)
+		return nil, fmt.Errorf("failed to get campaign count for workspace %s", campaign.WorkspaceID)
+	}
+
+	maxRate := campaign.MaxRatePerMin
+	if maxRate <= 0 {
+		maxRate = config.GetDefaultMaxRatePerMin()
+	}
+
+	answerRate := float64(stats.Answered) / float64(stats.Dialed)
+	handleTime := defaultHandleTime
+	if stats.Answered > 0 && stats.TotalHandleTime > 0 {
+		handleTime = stats.TotalHandleTime / time.Duration(stats.Answered)
+	}
+
+	dialRatio := CalculateDialRatio(answerRate, rc.targetAbandonRate)
+
+	// the agents and the queue belong to the workspace: leads already queued
+	// will be dialed first, calls in progress already keep agents busy, and
+	// what is left is shared by the active campaigns
+	needed := adaptiveInjectCount(stats.AvailableAgents, handleTime, dialRatio, timeWindow) - queueLength - currentCalls
+	injectCount := campaignShare(needed, campaigns)
+
+	// never exceed the campaign max rate for the window
+	ceiling := maxRate*int(timeWindow.Minutes()) - currentCalls - queueLength
+	if injectCount > ceiling {
+		injectCount = ceiling
+	}
+
+	if injectCount < 0 {
+		injectCount = 0
+	}
+
+	calculation := &RateCalculation{
+		CampaignID:             campaign.ID,
+		WorkspaceID:            campaign.WorkspaceID,
+		MaxRatePerMinute:       maxRate,
+		CurrentCallsInProgress: currentCalls,
+		CalculatedRate:         injectCount / int(timeWindow.Minutes()),
+		QueueDepth:             queueLength,
+		TimeWindow:             timeWindow,
+		InjectCount:            injectCount,
+		DialRatio:              dialRatio,
+	}
+
+	logger.Debug("adaptive rate calculation", logging.WorkspaceID, campaign.WorkspaceID, logging.CampaignID, campaign.ID,
+		"agents", stats.AvailableAgents, "answer_rate", answerRate, "handle_time", handleTime, "dial_ratio", dialRatio,
+		"queue", queueLength, "calls", currentCalls, "campaigns", campaigns, "inject", injectCount, "window", timeWindow)
+
+	return calculation, nil
+}
+
+// adaptiveInjectCount returns the number of dials needed to keep agents busy
+// for the window: each agent can take window/handleTime calls and every
+// answered call costs dialRatio dials
+func adaptiveInjectCount(agents int, handleTime time.Duration, dialRatio float64, window time.Duration) int {
+	if agents <= 0 || handleTime <= 0 {
+		return 0
+	}
+
+	callsPerAgent := float64(window) / float64(handleTime)
+	return int(math.Ceil(float64(agents) * callsPerAgent * dialRatio))
+}
+
+// campaignShare splits the dials a workspace needs across its active
+// campaigns, rounding up so a small need is not lost to every campaign
+func campaignShare(needed, campaigns int) int {
+	if needed <= 0 {
+		return 0
+	}
+
+	if campaigns <= 1 {
+		return needed
+	}
+
+	return (needed + campaigns - 1) / campaigns
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCalculateDialRatio tests the dial ratio stays under the abandon cap
+func TestCalculateDialRatio(t *testing.T) {
+	cases := []struct {
+		name              string
+		answerRate        float64
+		targetAbandonRate float64
+		expected          float64
+	}{
+		{name: "every call answered", answerRate: 1, targetAbandonRate: 0, expected: 1},
+		{name: "half answered no abandons", answerRate: 0.5, targetAbandonRate: 0, expected: 2},
+		{name: "half answered with abandon cap", answerRate: 0.5, targetAbandonRate: 0.2, expected: 2.5},
+		{name: "low answer rate is capped", answerRate: 0.1, targetAbandonRate: 0.03, expected: maxDialRatio},
+		{name: "no answers yet", answerRate: 0, targetAbandonRate: 0.03, expected: maxDialRatio},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result := CalculateDialRatio(c.answerRate, c.targetAbandonRate)
+			assert.InDelta(t, c.expected, result, 0.0001)
+		})
+	}
+}
+
+// TestAdaptiveInjectCount tests the number of dials needed to keep agents busy
+func TestAdaptiveInjectCount(t *testing.T) {
+	// 10 agents, 2 minute calls, 5 minute window: 25 answered calls needed
+	assert.Equal(t, 50, adaptiveInjectCount(10, 2*time.Minute, 2, 5*time.Minute))
+
+	// no agents, nothing to dial
+	assert.Equal(t, 0, adaptiveInjectCount(0, 2*time.Minute, 2, 5*time.Minute))
+}
+
+// TestCampaignShare tests the split of the workspace dials across campaigns
+func TestCampaignShare(t *testing.T) {
+	assert.Equal(t, 50, campaignShare(50, 1))
+	assert.Equal(t, 17, campaignShare(50, 3))
+
+	// no published campaigns yet, the campaign takes it all
+	assert.Equal(t, 50, campaignShare(50, 0))
+
+	// calls in progress and queued leads already cover the need
+	assert.Equal(t, 0, campaignShare(-5, 2))
+}
@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nico-phil/process/logging"
+	"github.com/redis/go-redis/v9"
+)
+
+// callbackQueuedTTL is how long a queued callback is remembered, a callback
+// whose status could not be saved is retried well within it
+const callbackQueuedTTL = 7 * 24 * time.Hour
+
+// queueCallbackScript pushes the lead of a callback to the callback lane once.
+// The callback key is set with the push, so a callback queued again after
+// its status could not be saved is not dialed twice.
+//
+// KEYS[1] callback key
+// KEYS[2] callback lane
+// KEYS[3] workspace signal
+// ARGV[1] lead payload
+// ARGV[2] callback key TTL in seconds
+//
+// Returns 1 when the lead was queued, 0 when the callback was queued before
+var queueCallbackScript = redis.NewScript(`
+if not redis.call('SET', KEYS[1], 1, 'NX', 'EX', ARGV[2]) then
+	return 0
+end
+
+redis.call('LPUSH', KEYS[2], ARGV[1])
+redis.call('LPUSH', KEYS[3], 1)
+redis.call('LTRIM', KEYS[3], 0, 999)
+redis.call('EXPIRE', KEYS[3], 3600)
+return 1
+`)
+
+// QueueCallback queues the lead of a callback in the callback lane of its
+// workspace, unless the callback was already queued. It reports whether the
+// lead was queued.
+func QueueCallback(ctx context.Context, workspaceID string, lead QueuedLead) (bool, error) {
+	if lead.CallbackID == "" {
+		return false, fmt.Errorf("lead %s has no callback", lead.LeadID)
+	}
+
+	payload, err := json.Marshal(lead)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal lead %v", err)
+	}
+
+	keys := []string{callbackKey(workspaceID, lead.CallbackID), laneKey(workspaceID, LaneCallback), signalKey(workspaceID)}
+	queued, err := queueCallbackScript.Run(ctx, rdb, keys, payload, int(callbackQueuedTTL.Seconds())).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to queue callback %s for workspace %s: %v", lead.CallbackID, workspaceID, err)
+	}
+
+	if queued == 0 {
+		return false, nil
+	}
+
+	if err := rdb.SAdd(ctx, activeWorkspacesKey, workspaceID).Err(); err != nil {
+		logger.Error("failed to register workspace", logging.WorkspaceID, workspaceID, "error", err)
+	}
+
+	return true, nil
+}
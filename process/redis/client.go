@@ -2,58 +2,152 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
-	"log"
+	"os"
 	"strconv"
 	"time"
 
 	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/logging"
 	"github.com/redis/go-redis/v9"
 )
 
+var logger = logging.For("redis")
+
 var (
-	rdb *redis.Client = nil
-	ctx               = context.Background()
+	rdb redis.UniversalClient = nil
+	ctx                       = context.Background()
 )
 
 // QueuedLead represents a lead in the queue - moved here to avoid circular imports
 type QueuedLead struct {
-	LeadID       string            `json:"lead_id"`
-	ListNumber   string            `json:"list_number"`
-	WorkspaceID  string            `json:"workspace_id"`
-	CampaignID   string            `json:"campaign_id"`
-	PhoneNumber  string            `json:"phone_number"`
-	FirstName    string            `json:"first_name"`
-	LastName     string            `json:"last_name"`
-	ZipCode      string            `json:"zip_code"`
-	ExtraData    map[string]string `json:"extra_data"`
-	QueuedAt     time.Time         `json:"queued_at"`
-	CallAttempts int               `json:"call_attempts"`
-	CallStatus   string            `json:"call_status"`
-}
-
-// InitRedis initiate the redis client
+	LeadID         string            `json:"lead_id"`
+	ListNumber     string            `json:"list_number"`
+	WorkspaceID    string            `json:"workspace_id"`
+	CampaignID     string            `json:"campaign_id"`
+	PhoneNumber    string            `json:"phone_number"`
+	FirstName      string            `json:"first_name"`
+	LastName       string            `json:"last_name"`
+	ZipCode        string            `json:"zip_code"`
+	ExtraData      map[string]string `json:"extra_data"`
+	QueuedAt       time.Time         `json:"queued_at"`
+	ExpiresAt      time.Time         `json:"expires_at"`
+	CallAttempts   int               `json:"call_attempts"`
+	CallStatus     string            `json:"call_status"`
+	CallbackID     string            `json:"callback_id,omitempty"`
+	AgentID        string            `json:"agent_id,omitempty"`
+	FailedAttempts int               `json:"failed_attempts,omitempty"`
+}
+
+// IsExpired reports whether the lead has been queued for too long to be
+// dialed. A lead queued without an expiry expires after the queued lead TTL.
+func (l QueuedLead) IsExpired(now time.Time) bool {
+	if l.ExpiresAt.IsZero() {
+		return now.After(l.QueuedAt.Add(config.GetQueuedLeadTTL()))
+	}
+
+	return now.After(l.ExpiresAt)
+}
+
+// InitRedis initiate the redis client for the configured deployment
 func InitRedis() error {
-	addr := config.GetRedisArr()
-	rdb = redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: config.GetRedisPassword(),
-		DB:       0,
-	})
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	client.AddHook(metricsHook{})
+	client.AddHook(tracingHook{})
 
-	pong, err := rdb.Ping(context.Background()).Result()
+	pong, err := client.Ping(context.Background()).Result()
 	if err != nil {
-		log.Printf("failed to connect t redis: %v", err)
-		return fmt.Errorf("failed to connect to redis %q", err)
+		client.Close()
+		return fmt.Errorf("failed to connect to redis: %v", err)
 	}
 
-	log.Printf("connected to redis: %s", pong)
+	// commands that fail later are retried on a new connection by the client
+	// itself, so a redis restart at runtime needs nothing from us
+	rdb = client
+
+	logger.Info("connected to redis", "mode", config.GetRedisMode(), "reply", pong)
 
 	return nil
 
 }
 
+// Ping checks the redis server answers
+func Ping(ctx context.Context) error {
+	if rdb == nil {
+		return ErrNoConnection
+	}
+
+	return rdb.Ping(ctx).Err()
+}
+
+// newClient creates a standalone, sentinel failover or cluster client
+func newClient() (redis.UniversalClient, error) {
+	options := &redis.UniversalOptions{
+		Addrs:      config.GetRedisAddrs(),
+		MasterName: config.GetRedisMasterName(),
+		Username:   config.GetRedisUsername(),
+		Password:   config.GetRedisPassword(),
+		DB:         0,
+	}
+
+	if config.GetRedisTLS() {
+		tlsConfig, err := newTLSConfig(config.GetRedisCAFile(), config.GetRedisTLSServerName())
+		if err != nil {
+			return nil, err
+		}
+		options.TLSConfig = tlsConfig
+	}
+
+	switch mode := config.GetRedisMode(); mode {
+	case "standalone":
+		return redis.NewClient(options.Simple()), nil
+	case "sentinel":
+		if options.MasterName == "" {
+			return nil, fmt.Errorf("redis sentinel mode requires REDIS_MASTER_NAME")
+		}
+		return redis.NewFailoverClient(options.Failover()), nil
+	case "cluster":
+		return redis.NewClusterClient(options.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", mode)
+	}
+}
+
+// newTLSConfig returns the TLS config of redis connections, trusting the
+// certificates of caFile on top of the system pool when it is set
+func newTLSConfig(caFile, serverName string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+
+	if caFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redis CA file: %v", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in redis CA file %s", caFile)
+	}
+	tlsConfig.RootCAs = pool
+
+	return tlsConfig, nil
+}
+
 // CloseRedis closes the Redis connection
 func CloseRedis() error {
 	if rdb != nil {
@@ -63,104 +157,142 @@ func CloseRedis() error {
 }
 
 // GetClient returns the Redis client
-func GetClient() *redis.Client {
+func GetClient() redis.UniversalClient {
 	return rdb
 }
 
-// IncrementCallCount increments call in progress
-func IncrementCallCount(workspaceID string) (int, error) {
-	key := fmt.Sprintf("ws_%s_calls_in_progress", workspaceID)
-	count, err := rdb.Incr(ctx, key).Result()
+// StartCallLease registers a call in progress for a workspace. The lease
+// expires after ttl unless it is renewed, so calls of a crashed dialer stop
+// counting on their own.
+func StartCallLease(workspaceID, callID string, ttl time.Duration) error {
+	key := callsInProgressKey(workspaceID)
+	expiresAt := time.Now().Add(ttl).UnixMilli()
+	err := rdb.ZAdd(ctx, key, redis.Z{Score: float64(expiresAt), Member: callID}).Err()
 	if err != nil {
-		return 0, fmt.Errorf("failed to increment call count for workspace %s, error: %v", workspaceID, err)
+		return fmt.Errorf("failed to start call lease %s for workspace %s, error: %v", callID, workspaceID, err)
 	}
 
-	return int(count), nil
+	return nil
 }
 
-// DecrementCallCount decrements call in progress
-func DecrementCallCount(workspaceID string) (int, error) {
-	key := fmt.Sprintf("ws_%s_calls_in_progress", workspaceID)
-	count, err := rdb.Decr(ctx, key).Result()
+// RenewCallLease pushes back the expiry of a call that is still in progress
+func RenewCallLease(workspaceID, callID string, ttl time.Duration) error {
+	key := callsInProgressKey(workspaceID)
+	expiresAt := time.Now().Add(ttl).UnixMilli()
+	updated, err := rdb.ZAddXX(ctx, key, redis.Z{Score: float64(expiresAt), Member: callID}).Result()
 	if err != nil {
-		return 0, fmt.Errorf("failed to decrement call count for workspace %s, error: %v", workspaceID, err)
+		return fmt.Errorf("failed to renew call lease %s for workspace %s, error: %v", callID, workspaceID, err)
 	}
 
-	if count <= 0 {
-		rdb.Set(ctx, key, 0, time.Hour)
-		count = 0
+	// ZADD XX reports 0 for updated members, check the lease is still there
+	if updated == 0 {
+		if _, err := rdb.ZScore(ctx, key, callID).Result(); err == redis.Nil {
+			return fmt.Errorf("failed to renew call lease %s for workspace %s, %w", callID, workspaceID, ErrLeaseExpired)
+		}
 	}
 
-	return int(count), nil
+	return nil
 }
 
-// GetCallCount retreive the amount of call in progress
-func GetCallCount(workspaceID string) (int, error) {
-	key := fmt.Sprintf("ws_%s_calls_in_progress", workspaceID)
-	countStr, err := rdb.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return 0, nil // No calls count set
+// EndCallLease removes a call from the calls in progress. It reports false
+// when the call had no lease left: it already ended or its lease expired.
+func EndCallLease(workspaceID, callID string) (bool, error) {
+	key := callsInProgressKey(workspaceID)
+	removed, err := rdb.ZRem(ctx, key, callID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to end call lease %s for workspace %s, error: %v", callID, workspaceID, err)
 	}
 
+	return removed > 0, nil
+}
+
+// GetCallCount retreive the amount of call in progress, dropping expired leases
+func GetCallCount(ctx context.Context, workspaceID string) (int, error) {
+	key := callsInProgressKey(workspaceID)
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	var count *redis.IntCmd
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", now)
+		count = pipe.ZCard(ctx, key)
+		return nil
+	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to retrieve call count: %v", err)
 	}
 
-	count, _ := strconv.Atoi(countStr)
-
-	return count, nil
+	return int(count.Val()), nil
 }
 
-// GetQueueLength retrieve length of the queue for a single workspace
-func GetQueueLength(workspaceID string) (int, error) {
-	key := fmt.Sprintf("ws_%s", workspaceID)
-	length, err := rdb.LLen(ctx, key).Result()
+// GetQueueLength retrieve length of the queue for a single workspace, across all lanes
+func GetQueueLength(ctx context.Context, workspaceID string) (int, error) {
+	lengths := make([]*redis.IntCmd, 0, len(queueLanes))
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range laneKeys(workspaceID) {
+			lengths = append(lengths, pipe.LLen(ctx, key))
+		}
+		return nil
+	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to get length of the queue")
 	}
 
-	return int(length), nil
+	total := 0
+	for _, length := range lengths {
+		total += int(length.Val())
+	}
+
+	return total, nil
 }
 
-// GetWorkspaceQueues returns all workspace queue keys
-func GetWorkspaceQueues() ([]string, error) {
-	// keys, err := rdb.Keys(ctx, "ws_*").Result()
-	keys, _, err := rdb.Scan(ctx, 0, "ws_*", 100).Result()
+// GetLaneLength retrieve length of a single lane of a workspace queue
+func GetLaneLength(workspaceID string, lane Lane) (int, error) {
+	length, err := rdb.LLen(ctx, laneKey(workspaceID, lane)).Result()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get workspace queues: %v", err)
+		return 0, fmt.Errorf("failed to get length of the %s lane", lane)
 	}
 
-	// Filter out call count keys
-	var queueKeys []string
-	for _, key := range keys {
-		if !isCallCountKey(key) {
-			queueKeys = append(queueKeys, key)
+	return int(length), nil
+}
+
+// CacheCampaignRates replaces the cached max rates per minute of the
+// campaigns of a workspace, and the set of campaigns that have one, which
+// tells the dequeue script the campaign keys to take tokens from. Like the
+// workspace rate they expire after rateCacheTTL, so the rate of a deleted
+// campaign is not enforced forever.
+func CacheCampaignRates(ctx context.Context, workspaceID string, rates map[string]int) error {
+	ttl := rateCacheTTL()
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, ratedCampaignsKey(workspaceID))
+		for campaignID, maxRate := range rates {
+			pipe.Set(ctx, campaignRateKey(workspaceID, campaignID), maxRate, ttl)
+			pipe.SAdd(ctx, ratedCampaignsKey(workspaceID), campaignID)
 		}
+		pipe.Expire(ctx, ratedCampaignsKey(workspaceID), ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cache campaign rates: %v", err)
 	}
 
-	return queueKeys, nil
-}
-
-// isCallCountKey checks if a key is a call count key
-func isCallCountKey(key string) bool {
-	return len(key) > 18 && key[len(key)-18:] == "_calls_in_progress"
+	logger.Debug("cached campaign max rates", logging.WorkspaceID, workspaceID, "campaigns", len(rates))
+	return nil
 }
 
-// CacheCampaignRate caches campaign max rate per minute
-func CacheCampaignRate(campaignID string, maxRate int) error {
-	key := fmt.Sprintf("campaign_%s_max_rate", campaignID)
-	err := rdb.Set(ctx, key, maxRate, time.Hour).Err()
+// GetRatedCampaignCount returns how many campaigns of a workspace had a rate
+// cached by the last cycle, the campaigns the workspace is dialing for
+func GetRatedCampaignCount(ctx context.Context, workspaceID string) (int, error) {
+	count, err := rdb.SCard(ctx, ratedCampaignsKey(workspaceID)).Result()
 	if err != nil {
-		return fmt.Errorf("failed to cache campaign rate: %v", err)
+		return 0, fmt.Errorf("failed to retrieve rated campaign count: %v", err)
 	}
 
-	log.Printf("Cached campaign %s max rate: %d", campaignID, maxRate)
-	return nil
+	return int(count), nil
 }
 
 // GetCachedCampaignRate retrieves cached campaign max rate
-func GetCachedCampaignRate(campaignID string) (int, error) {
-	key := fmt.Sprintf("campaign_%s_max_rate", campaignID)
+func GetCachedCampaignRate(workspaceID, campaignID string) (int, error) {
+	key := campaignRateKey(workspaceID, campaignID)
 	rate, err := rdb.Get(ctx, key).Int()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get cached campaign rate: %v", err)
@@ -169,35 +301,142 @@ func GetCachedCampaignRate(campaignID string) (int, error) {
 	return rate, nil
 }
 
-// QueueLead inserts lead for a workspace
-func QueueLead(workspaceID string, lead QueuedLead) error {
-	key := fmt.Sprintf("ws_%s", workspaceID)
+// IncrementListDailyCount adds n to the number of leads injected from a list today
+func IncrementListDailyCount(ctx context.Context, workspaceID, listNumber string, day time.Time, n int) (int, error) {
+	key := listDailyCountKey(workspaceID, listNumber, day)
+	count, err := rdb.IncrBy(ctx, key, int64(n)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment daily count for list %s, error: %v", listNumber, err)
+	}
+
+	// keep the counter a little past midnight so late reads don't reset to zero
+	rdb.Expire(ctx, key, 48*time.Hour)
+
+	return int(count), nil
+}
+
+// GetListDailyCount retrieves the number of leads injected from a list today
+func GetListDailyCount(ctx context.Context, workspaceID, listNumber string, day time.Time) (int, error) {
+	key := listDailyCountKey(workspaceID, listNumber, day)
+	count, err := rdb.Get(ctx, key).Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve daily count for list %s: %v", listNumber, err)
+	}
+
+	return count, nil
+}
+
+// QueueLead inserts lead for a workspace in the regular lane
+func QueueLead(ctx context.Context, workspaceID string, lead QueuedLead) error {
+	return QueueLeadInLane(ctx, workspaceID, LaneRegular, lead)
+}
+
+// QueueLeadInLane inserts lead for a workspace in a priority lane
+func QueueLeadInLane(ctx context.Context, workspaceID string, lane Lane, lead QueuedLead) error {
+	if !isValidLane(lane) {
+		return fmt.Errorf("unknown queue lane %q", lane)
+	}
 
 	jsonLead, err := json.Marshal(lead)
 	if err != nil {
 		return fmt.Errorf("failed to marshal lead %v", err)
 	}
-	_, err = rdb.LPush(ctx, key, jsonLead).Result()
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, laneKey(workspaceID, lane), jsonLead)
+		// wake up a dialer blocked in BlockingDequeueLead
+		pipe.LPush(ctx, signalKey(workspaceID), 1)
+		pipe.LTrim(ctx, signalKey(workspaceID), 0, 999)
+		pipe.Expire(ctx, signalKey(workspaceID), time.Hour)
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to queue lead for workspace: %s : %v", workspaceID, err)
 	}
 
+	// the active workspace set lives in another cluster slot than the queue,
+	// so it cannot join the transaction
+	if err := rdb.SAdd(ctx, activeWorkspacesKey, workspaceID).Err(); err != nil {
+		logger.Error("failed to register workspace", logging.WorkspaceID, workspaceID, "error", err)
+	}
+
 	return nil
 }
 
-func DequeueLead(workspaceID string) (*QueuedLead, error) {
-	key := fmt.Sprintf("ws_%s", workspaceID)
-	leadJson, err := rdb.RPop(ctx, key).Result()
-	if err != nil {
-		return nil, fmt.Errorf("failed to dequeue lead for workspace %s, %v", workspaceID, err)
+// RemoveQueuedLeads removes every lead of a workspace queue matching fn, in
+// any lane, and returns the removed leads. Leads popped by a dialer in the
+// meantime are not reported.
+func RemoveQueuedLeads(workspaceID string, fn func(QueuedLead) bool) ([]QueuedLead, error) {
+	var removed []QueuedLead
+	for _, key := range laneKeys(workspaceID) {
+		payloads, err := rdb.LRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return removed, fmt.Errorf("failed to read queue for workspace %s: %v", workspaceID, err)
+		}
+
+		for _, payload := range payloads {
+			var lead QueuedLead
+			if err := json.Unmarshal([]byte(payload), &lead); err != nil {
+				continue
+			}
+
+			if !fn(lead) {
+				continue
+			}
+
+			count, err := rdb.LRem(ctx, key, 1, payload).Result()
+			if err != nil {
+				return removed, fmt.Errorf("failed to remove lead %s for workspace %s: %v", lead.LeadID, workspaceID, err)
+			}
+
+			if count > 0 {
+				removed = append(removed, lead)
+			}
+		}
 	}
 
-	var lead QueuedLead
-	err = json.Unmarshal([]byte(leadJson), &lead)
+	return removed, nil
+}
+
+// GetActiveLists returns the lists stored by the last cleanup run that
+// completed. stored is false until a run stored them.
+func GetActiveLists() (lists []string, stored bool, err error) {
+	members, err := rdb.SMembers(ctx, activeListsKey).Result()
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarsha lead: %v", err)
+		return nil, false, fmt.Errorf("failed to get active lists: %v", err)
 	}
 
-	return &lead, nil
+	for _, member := range members {
+		if member == activeListsMarker {
+			stored = true
+			continue
+		}
+
+		lists = append(lists, member)
+	}
 
+	return lists, stored, nil
+}
+
+// StoreActiveLists replaces the lists active during the last cleanup run
+func StoreActiveLists(lists []string) error {
+	// the marker keeps the set, and tells a run stored it, when no list is active
+	args := []any{activeListsMarker}
+	for _, list := range lists {
+		args = append(args, list)
+	}
+
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, activeListsKey)
+		pipe.SAdd(ctx, activeListsKey, args...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store active lists: %v", err)
+	}
+
+	return nil
 }
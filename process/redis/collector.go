@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/nico-phil/process/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// collectTimeout bounds the redis reads of a scrape
+const collectTimeout = 5 * time.Second
+
+func init() {
+	prometheus.MustRegister(workspaceCollector{})
+}
+
+// workspaceCollector reads the queue depth and calls in progress of every
+// active workspace when metrics are scraped, so the values are current and a
+// workspace that stopped being active has no series left behind
+type workspaceCollector struct{}
+
+// Describe implements prometheus.Collector
+func (workspaceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metrics.QueueDepth
+	ch <- metrics.CallsInProgress
+}
+
+// Collect implements prometheus.Collector
+func (workspaceCollector) Collect(ch chan<- prometheus.Metric) {
+	if rdb == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	workspaces, err := rdb.SMembers(ctx, activeWorkspacesKey).Result()
+	if err != nil {
+		logger.Error("failed to collect workspace metrics", "error", err)
+		return
+	}
+
+	// expired leases are counted out without being removed, a scrape only reads
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	depths := make([][]*redis.IntCmd, len(workspaces))
+	calls := make([]*redis.IntCmd, len(workspaces))
+	_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, workspaceID := range workspaces {
+			for _, key := range laneKeys(workspaceID) {
+				depths[i] = append(depths[i], pipe.LLen(ctx, key))
+			}
+			calls[i] = pipe.ZCount(ctx, callsInProgressKey(workspaceID), "("+now, "+inf")
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("failed to collect workspace metrics", "error", err)
+		return
+	}
+
+	for i, workspaceID := range workspaces {
+		depth := int64(0)
+		for _, length := range depths[i] {
+			depth += length.Val()
+		}
+
+		ch <- prometheus.MustNewConstMetric(metrics.QueueDepth, prometheus.GaugeValue, float64(depth), workspaceID)
+		ch <- prometheus.MustNewConstMetric(metrics.CallsInProgress, prometheus.GaugeValue, float64(calls[i].Val()), workspaceID)
+	}
+}
@@ -0,0 +1,85 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RecordCampaignDisposition adds an answered or abandoned call to the campaign's daily counters
+func RecordCampaignDisposition(campaignID string, answered, abandoned bool, retention time.Duration) error {
+	key := dispositionBucketKey(campaignID, time.Now())
+
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if answered {
+			pipe.HIncrBy(ctx, key, "answered", 1)
+		}
+		if abandoned {
+			pipe.HIncrBy(ctx, key, "abandoned", 1)
+		}
+		pipe.Expire(ctx, key, retention)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record disposition for campaign %s: %v", campaignID, err)
+	}
+
+	return nil
+}
+
+// GetCampaignDispositions sums answered and abandoned calls of the last days
+func GetCampaignDispositions(ctx context.Context, campaignID string, days int) (int, int, error) {
+	now := time.Now()
+
+	buckets := make([]*redis.MapStringStringCmd, 0, days)
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := 0; i < days; i++ {
+			buckets = append(buckets, pipe.HGetAll(ctx, dispositionBucketKey(campaignID, now.AddDate(0, 0, -i))))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get dispositions for campaign %s: %v", campaignID, err)
+	}
+
+	answered, abandoned := 0, 0
+	for _, bucket := range buckets {
+		values := bucket.Val()
+		a, _ := strconv.Atoi(values["answered"])
+		b, _ := strconv.Atoi(values["abandoned"])
+		answered += a
+		abandoned += b
+	}
+
+	return answered, abandoned, nil
+}
+
+// SetCampaignCompliance stores the last computed compliance state of a campaign
+func SetCampaignCompliance(ctx context.Context, campaignID string, state []byte) error {
+	key := complianceKey(campaignID)
+	err := rdb.Set(ctx, key, state, 24*time.Hour).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set compliance for campaign %s: %v", campaignID, err)
+	}
+
+	return nil
+}
+
+// GetCampaignCompliance retrieves the last computed compliance state of a
+// campaign, nil when none has been stored
+func GetCampaignCompliance(campaignID string) ([]byte, error) {
+	key := complianceKey(campaignID)
+	state, err := rdb.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get compliance for campaign %s: %v", campaignID, err)
+	}
+
+	return state, nil
+}
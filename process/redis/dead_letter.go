@@ -0,0 +1,191 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// maxDeadLetters bounds the dead letter queue of a workspace, the cleanup run
+// trims the oldest entries beyond it
+const maxDeadLetters = 10000
+
+// DeadLetter is a queue entry that could not be dialed, with the reason why
+type DeadLetter struct {
+	ID       string    `json:"id"`
+	Payload  string    `json:"payload"`
+	Reason   string    `json:"reason"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// DeadLetterPayload moves a raw queue entry to the dead letter queue of a
+// workspace. The queue is bounded by TrimDeadLetters.
+func DeadLetterPayload(workspaceID, payload, reason string) error {
+	now := time.Now()
+	entry, err := json.Marshal(DeadLetter{
+		ID:       strconv.FormatInt(now.UnixNano(), 10),
+		Payload:  payload,
+		Reason:   reason,
+		FailedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter %v", err)
+	}
+
+	key := deadLetterKey(workspaceID)
+	if err := rdb.LPush(ctx, key, entry).Err(); err != nil {
+		return fmt.Errorf("failed to dead letter entry for workspace %s: %v", workspaceID, err)
+	}
+
+	return nil
+}
+
+// DeadLetterLead moves a lead to the dead letter queue of a workspace
+func DeadLetterLead(workspaceID string, lead QueuedLead, reason string) error {
+	payload, err := json.Marshal(lead)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lead %v", err)
+	}
+
+	return DeadLetterPayload(workspaceID, string(payload), reason)
+}
+
+// GetDeadLetters returns dead letters of a workspace, newest first
+func GetDeadLetters(workspaceID string, offset, limit int) ([]DeadLetter, error) {
+	entries, err := rdb.LRange(ctx, deadLetterKey(workspaceID), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letters for workspace %s: %v", workspaceID, err)
+	}
+
+	deadLetters := make([]DeadLetter, 0, len(entries))
+	for _, entry := range entries {
+		var deadLetter DeadLetter
+		if err := json.Unmarshal([]byte(entry), &deadLetter); err != nil {
+			continue
+		}
+
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, nil
+}
+
+// GetDeadLetterCount returns the length of the dead letter queue of a workspace
+func GetDeadLetterCount(workspaceID string) (int, error) {
+	count, err := rdb.LLen(ctx, deadLetterKey(workspaceID)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count dead letters for workspace %s: %v", workspaceID, err)
+	}
+
+	return int(count), nil
+}
+
+// ReplayDeadLetters queues dead letters of a workspace again in the retry
+// lane, all of them when id is empty. Entries that are not a valid lead stay
+// in the dead letter queue. It returns the number of leads replayed.
+func ReplayDeadLetters(workspaceID, id string) (int, error) {
+	key := deadLetterKey(workspaceID)
+	entries, err := rdb.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get dead letters for workspace %s: %v", workspaceID, err)
+	}
+
+	replayed := 0
+	for _, entry := range entries {
+		var deadLetter DeadLetter
+		if err := json.Unmarshal([]byte(entry), &deadLetter); err != nil {
+			continue
+		}
+
+		if id != "" && deadLetter.ID != id {
+			continue
+		}
+
+		var lead QueuedLead
+		if err := json.Unmarshal([]byte(deadLetter.Payload), &lead); err != nil || lead.LeadID == "" {
+			continue
+		}
+
+		removed, err := rdb.LRem(ctx, key, 1, entry).Result()
+		if err != nil {
+			return replayed, fmt.Errorf("failed to remove dead letter %s for workspace %s: %v", deadLetter.ID, workspaceID, err)
+		}
+
+		if removed == 0 {
+			continue
+		}
+
+		lead.FailedAttempts = 0
+		lead.QueuedAt = time.Now()
+		if err := QueueLeadInLane(ctx, workspaceID, LaneRetry, lead); err != nil {
+			return replayed, err
+		}
+
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+// PurgeDeadLetters deletes the dead letter queue of a workspace. It returns
+// how many entries it held and the leads among them, which the caller gives
+// back to cassandra as they are still taken there.
+func PurgeDeadLetters(workspaceID string) ([]QueuedLead, int, error) {
+	key := deadLetterKey(workspaceID)
+
+	var entries *redis.StringSliceCmd
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		entries = pipe.LRange(ctx, key, 0, -1)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to purge dead letters for workspace %s: %v", workspaceID, err)
+	}
+
+	return deadLetterLeads(entries.Val()), len(entries.Val()), nil
+}
+
+// TrimDeadLetters drops the oldest dead letters of a workspace beyond
+// maxDeadLetters. It returns how many entries it dropped and the leads among
+// them, which the caller marks terminal in cassandra as nothing holds them
+// anymore.
+func TrimDeadLetters(workspaceID string) ([]QueuedLead, int, error) {
+	key := deadLetterKey(workspaceID)
+
+	var entries *redis.StringSliceCmd
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		entries = pipe.LRange(ctx, key, maxDeadLetters, -1)
+		pipe.LTrim(ctx, key, 0, maxDeadLetters-1)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to trim dead letters for workspace %s: %v", workspaceID, err)
+	}
+
+	return deadLetterLeads(entries.Val()), len(entries.Val()), nil
+}
+
+// deadLetterLeads decodes the leads of dead letter entries, skipping entries
+// that do not hold a valid lead
+func deadLetterLeads(entries []string) []QueuedLead {
+	var leads []QueuedLead
+	for _, entry := range entries {
+		var deadLetter DeadLetter
+		if err := json.Unmarshal([]byte(entry), &deadLetter); err != nil {
+			continue
+		}
+
+		var lead QueuedLead
+		if err := json.Unmarshal([]byte(deadLetter.Payload), &lead); err != nil || lead.LeadID == "" {
+			continue
+		}
+
+		leads = append(leads, lead)
+	}
+
+	return leads
+}
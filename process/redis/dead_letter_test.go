@@ -0,0 +1,35 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTrimDeadLetters tests the oldest dead letters beyond the bound are
+// dropped and their leads returned
+func TestTrimDeadLetters(t *testing.T) {
+	m := useMiniredis(t)
+
+	require.NoError(t, DeadLetterLead("ws1", QueuedLead{LeadID: "oldest", ListNumber: "l1"}, "busy"))
+	require.NoError(t, DeadLetterPayload("ws1", "{bad", "malformed payload"))
+	for i := 0; i < maxDeadLetters; i++ {
+		_, err := m.Lpush(deadLetterKey("ws1"), `{"payload":"{}"}`)
+		require.NoError(t, err)
+	}
+
+	leads, trimmed, err := TrimDeadLetters("ws1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, trimmed)
+	require.Len(t, leads, 1)
+	assert.Equal(t, "oldest", leads[0].LeadID)
+
+	count, err := GetDeadLetterCount("ws1")
+	require.NoError(t, err)
+	assert.Equal(t, maxDeadLetters, count)
+
+	_, trimmed, err = TrimDeadLetters("ws1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, trimmed)
+}
@@ -0,0 +1,188 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/nico-phil/process/logging"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrQueueEmpty is returned when a workspace has no lead to dequeue
+	ErrQueueEmpty = errors.New("redis: queue is empty")
+
+	// ErrNoConnection is returned before InitRedis created the client
+	ErrNoConnection = errors.New("redis: no connection")
+
+	// ErrLeaseExpired is returned when renewing a call lease that already expired
+	ErrLeaseExpired = errors.New("redis: call lease expired")
+
+	// ErrInvalidCount is returned when asking DequeueLeads for no lead
+	ErrInvalidCount = errors.New("redis: count must be positive")
+)
+
+// MalformedLeadsError is returned by DequeueLeads when every popped entry
+// failed to decode. The entries were moved to the dead letter queue.
+type MalformedLeadsError struct {
+	WorkspaceID string
+	Count       int
+}
+
+func (e *MalformedLeadsError) Error() string {
+	return fmt.Sprintf("%d malformed leads for workspace %s moved to the dead letter queue", e.Count, e.WorkspaceID)
+}
+
+// dequeueHook is called with the leads popped by DequeueLeads
+var dequeueHook atomic.Pointer[func(workspaceID string, leads []QueuedLead)]
+
+// OnDequeue registers a function called with the leads every DequeueLeads
+// pops, e.g. to record them in the audit history, which this package cannot
+// reach. It runs once the leads left the queue and cannot keep them there.
+func OnDequeue(hook func(workspaceID string, leads []QueuedLead)) {
+	dequeueHook.Store(&hook)
+}
+
+// maxBlockWait bounds a single wait of BlockingDequeueLead so that leads
+// queued without a signal are still picked up
+const maxBlockWait = 5 * time.Second
+
+// DequeueLead pops the oldest lead of a workspace, enforcing the workspace and
+// campaign rate limits. ErrQueueEmpty is returned when there is nothing to
+// dial and a *RateLimitedError when a bucket is empty.
+func DequeueLead(workspaceID string) (*QueuedLead, error) {
+	leads, err := DequeueLeads(workspaceID, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return &leads[0], nil
+}
+
+// DequeueLeads pops up to count leads of a workspace in a single round trip.
+// It returns the leads popped before a bucket ran empty, and the same errors
+// as DequeueLead when none could be popped. A count below one is an
+// ErrInvalidCount, a batch of entries that all failed to decode a
+// *MalformedLeadsError.
+func DequeueLeads(workspaceID string, count int) ([]QueuedLead, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("failed to dequeue %d leads for workspace %s, %w", count, workspaceID, ErrInvalidCount)
+	}
+
+	payloads, wait, err := popLeadsWithRateLimit(workspaceID, count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dequeue lead for workspace %s, %w", workspaceID, err)
+	}
+
+	if len(payloads) == 0 {
+		if wait > 0 {
+			return nil, &RateLimitedError{WorkspaceID: workspaceID, RetryAfter: wait}
+		}
+		return nil, fmt.Errorf("failed to dequeue lead for workspace %s, %w", workspaceID, ErrQueueEmpty)
+	}
+
+	leads := make([]QueuedLead, 0, len(payloads))
+	for _, payload := range payloads {
+		var lead QueuedLead
+		if err := json.Unmarshal([]byte(payload), &lead); err != nil {
+			logger.Warn("malformed queued lead", logging.WorkspaceID, workspaceID, "error", err)
+			// the payload is already popped, keep it for inspection
+			if err := DeadLetterPayload(workspaceID, payload, fmt.Sprintf("malformed payload: %v", err)); err != nil {
+				logger.Error("failed to dead letter payload", logging.WorkspaceID, workspaceID, "error", err)
+			}
+			continue
+		}
+
+		leads = append(leads, lead)
+	}
+
+	if len(leads) == 0 {
+		return nil, &MalformedLeadsError{WorkspaceID: workspaceID, Count: len(payloads)}
+	}
+
+	if hook := dequeueHook.Load(); hook != nil {
+		(*hook)(workspaceID, leads)
+	}
+
+	return leads, nil
+}
+
+// BlockingDequeueLead pops the oldest lead of a workspace, waiting for one
+// to be queued or for a rate limit token until ctx is done. It returns
+// ErrQueueEmpty when the ctx deadline passes without a lead.
+func BlockingDequeueLead(ctx context.Context, workspaceID string) (*QueuedLead, error) {
+	for {
+		lead, err := DequeueLead(workspaceID)
+		if err == nil {
+			return lead, nil
+		}
+
+		var rateLimited *RateLimitedError
+		switch {
+		case errors.As(err, &rateLimited):
+			if err := sleep(ctx, rateLimited.RetryAfter); err != nil {
+				return nil, dequeueTimeout(workspaceID, err)
+			}
+		case errors.Is(err, ErrQueueEmpty):
+			if err := waitForLead(ctx, workspaceID); err != nil {
+				return nil, dequeueTimeout(workspaceID, err)
+			}
+		default:
+			return nil, err
+		}
+	}
+}
+
+// waitForLead blocks until a lead is signaled for the workspace, maxBlockWait
+// elapses or ctx is done
+func waitForLead(ctx context.Context, workspaceID string) error {
+	timeout := maxBlockWait
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+
+	if timeout <= 0 {
+		return context.DeadlineExceeded
+	}
+
+	// BRPOP only blocks in whole seconds, poll the last second before the deadline
+	if timeout < time.Second {
+		return sleep(ctx, min(timeout, 100*time.Millisecond))
+	}
+
+	err := rdb.BRPop(ctx, timeout.Truncate(time.Second), signalKey(workspaceID)).Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// dequeueTimeout maps the end of a blocking wait to the error returned to the dialer
+func dequeueTimeout(workspaceID string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("failed to dequeue lead for workspace %s, %w", workspaceID, ErrQueueEmpty)
+	}
+
+	return fmt.Errorf("failed to dequeue lead for workspace %s, %w", workspaceID, err)
+}
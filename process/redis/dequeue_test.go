@@ -0,0 +1,39 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDequeueLeadsInvalidCount tests a count below one is rejected before
+// reaching redis
+func TestDequeueLeadsInvalidCount(t *testing.T) {
+	for _, count := range []int{0, -1} {
+		leads, err := DequeueLeads("ws1", count)
+		assert.Nil(t, leads)
+		assert.True(t, errors.Is(err, ErrInvalidCount))
+	}
+}
+
+// TestDequeueLeadsCallsHook tests the dequeue hook gets the popped leads
+func TestDequeueLeadsCallsHook(t *testing.T) {
+	useMiniredis(t)
+	defer dequeueHook.Store(nil)
+
+	var hooked []string
+	OnDequeue(func(workspaceID string, leads []QueuedLead) {
+		for _, lead := range leads {
+			hooked = append(hooked, workspaceID+"/"+lead.LeadID)
+		}
+	})
+
+	require.NoError(t, QueueLead(context.Background(), "ws1", QueuedLead{LeadID: "l1", QueuedAt: time.Now()}))
+	_, err := DequeueLeads("ws1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ws1/l1"}, hooked)
+}
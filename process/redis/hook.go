@@ -0,0 +1,138 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/nico-phil/process/metrics"
+	"github.com/nico-phil/process/tracing"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("redis")
+
+// metricsHook records the latency and errors of every command and pipeline
+type metricsHook struct{}
+
+// DialHook implements redis.Hook
+func (metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := next(ctx, network, addr)
+		observe("dial", start, err)
+		return conn, err
+	}
+}
+
+// ProcessHook implements redis.Hook
+func (metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		observe(cmd.Name(), start, err)
+		return err
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook
+func (metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		observe("pipeline", start, err)
+		return err
+	}
+}
+
+// observe records a command, a missing key is not an error
+func observe(command string, start time.Time, err error) {
+	metrics.RedisDuration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+	if err != nil && err != redis.Nil {
+		metrics.RedisErrors.WithLabelValues(command).Inc()
+	}
+}
+
+// tracingHook records a span for every command and pipeline run with a traced
+// context. Only the command and its first key are recorded, values can hold
+// lead details.
+type tracingHook struct{}
+
+// DialHook implements redis.Hook
+func (tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook implements redis.Hook
+func (tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !tracing.Traced(ctx) {
+			return next(ctx, cmd)
+		}
+
+		ctx, span := tracer.Start(ctx, cmd.Name(), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			attribute.String("db.system.name", "redis"),
+			attribute.String("db.operation.name", cmd.Name()),
+			attribute.String("db.redis.key", commandKey(cmd)),
+		))
+		err := next(ctx, cmd)
+		if err == redis.Nil {
+			span.End()
+			return err
+		}
+
+		tracing.End(span, err)
+		return err
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook
+func (tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !tracing.Traced(ctx) {
+			return next(ctx, cmds)
+		}
+
+		names := make([]string, len(cmds))
+		keys := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			names[i] = cmd.Name()
+			keys[i] = commandKey(cmd)
+		}
+
+		ctx, span := tracer.Start(ctx, "pipeline", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			attribute.String("db.system.name", "redis"),
+			attribute.StringSlice("db.redis.commands", names),
+			attribute.StringSlice("db.redis.keys", keys),
+			attribute.Int("db.operation.batch.size", len(cmds)),
+		))
+		err := next(ctx, cmds)
+		if err == redis.Nil {
+			span.End()
+			return err
+		}
+
+		tracing.End(span, err)
+		return err
+	}
+}
+
+// commandKey returns the first key of a command, scripts pass theirs after
+// the script and the number of keys
+func commandKey(cmd redis.Cmder) string {
+	args := cmd.Args()
+	index := 1
+	switch cmd.Name() {
+	case "eval", "evalsha", "eval_ro", "evalsha_ro":
+		index = 3
+	}
+
+	if len(args) <= index {
+		return ""
+	}
+
+	return fmt.Sprint(args[index])
+}
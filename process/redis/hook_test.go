@@ -0,0 +1,26 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCommandKey tests the first key of a command is found, scripts included
+func TestCommandKey(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		cmd      redis.Cmder
+		expected string
+	}{
+		{redis.NewIntCmd(ctx, "llen", "ws_{ws1}"), "ws_{ws1}"},
+		{redis.NewCmd(ctx, "evalsha", "abc123", 2, "ws_{ws1}", "workspace_{ws1}_bucket"), "ws_{ws1}"},
+		{redis.NewStatusCmd(ctx, "ping"), ""},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, commandKey(c.cmd), c.cmd.Name())
+	}
+}
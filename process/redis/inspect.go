@@ -0,0 +1,87 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LaneLength is the number of leads waiting in a lane of a workspace queue
+type LaneLength struct {
+	Lane   Lane
+	Length int
+}
+
+// GetLaneLengths returns the length of every lane of a workspace queue,
+// highest priority first
+func GetLaneLengths(workspaceID string) ([]LaneLength, error) {
+	cmds := make([]*redis.IntCmd, len(queueLanes))
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, lane := range queueLanes {
+			cmds[i] = pipe.LLen(ctx, laneKey(workspaceID, lane))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lane lengths for workspace %s: %v", workspaceID, err)
+	}
+
+	lengths := make([]LaneLength, len(queueLanes))
+	for i, lane := range queueLanes {
+		lengths[i] = LaneLength{Lane: lane, Length: int(cmds[i].Val())}
+	}
+
+	return lengths, nil
+}
+
+// PeekQueuedLeads returns up to count leads of a lane in the order they will
+// be dequeued, without removing them. Entries that are not valid leads are skipped.
+func PeekQueuedLeads(workspaceID string, lane Lane, count int) ([]QueuedLead, error) {
+	if !isValidLane(lane) {
+		return nil, fmt.Errorf("unknown queue lane %q", lane)
+	}
+
+	// leads are pushed on the left and dequeued from the right
+	payloads, err := rdb.LRange(ctx, laneKey(workspaceID, lane), int64(-count), -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s lane for workspace %s: %v", lane, workspaceID, err)
+	}
+
+	leads := make([]QueuedLead, 0, len(payloads))
+	for i := len(payloads) - 1; i >= 0; i-- {
+		var lead QueuedLead
+		if err := json.Unmarshal([]byte(payloads[i]), &lead); err != nil {
+			continue
+		}
+		leads = append(leads, lead)
+	}
+
+	return leads, nil
+}
+
+// PurgeQueue deletes every lane of a workspace queue, malformed entries
+// included, and returns how many entries were dropped. The leads stay non
+// dialable in cassandra until reconciliation restores them.
+func PurgeQueue(workspaceID string) (int, error) {
+	keys := laneKeys(workspaceID)
+	lengths := make([]*redis.IntCmd, len(keys))
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			lengths[i] = pipe.LLen(ctx, key)
+		}
+		pipe.Del(ctx, keys...)
+		pipe.Del(ctx, signalKey(workspaceID))
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge queue for workspace %s: %v", workspaceID, err)
+	}
+
+	purged := 0
+	for _, length := range lengths {
+		purged += int(length.Val())
+	}
+
+	return purged, nil
+}
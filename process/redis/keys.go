@@ -0,0 +1,176 @@
+package redis
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Every redis key used by the process is built and parsed here. Workspace
+// queues are named ws_{<id>}, every other workspace scoped key starts with
+// workspace_{<id>}_ so it can never be mistaken for a queue. The braces are a
+// redis cluster hash tag: every key of a workspace, including the rate keys
+// of its campaigns, lands in the same slot so the dequeue script and
+// transactions can touch them together.
+
+const (
+	// queueKeyPrefix starts the name of every workspace queue lane
+	queueKeyPrefix = "ws_"
+	// laneKeySeparator separates the workspace from the lane of a priority lane key
+	laneKeySeparator = "_lane_"
+	// legacyCallsInProgressSuffix ends the calls in progress keys of earlier
+	// releases, which shared the queue prefix
+	legacyCallsInProgressSuffix = "_calls_in_progress"
+	// campaignKeyPrefix starts every campaign scoped key
+	campaignKeyPrefix = "campaign_"
+
+	// activeWorkspacesKey is the set of workspaces that have queued leads
+	activeWorkspacesKey = "active_workspaces"
+	// activeListsKey is the set of lists active during the last cleanup run
+	activeListsKey = "cleanup_active_lists"
+	// activeListsMarker is always a member of activeListsKey once stored
+	activeListsMarker = "*"
+)
+
+// hashTag wraps a workspace id so that cluster slots are computed on it alone
+func hashTag(workspaceID string) string {
+	return "{" + workspaceID + "}"
+}
+
+// laneKey returns the list key of a workspace queue lane
+func laneKey(workspaceID string, lane Lane) string {
+	if lane == LaneRegular {
+		return queueKeyPrefix + hashTag(workspaceID)
+	}
+
+	return queueKeyPrefix + hashTag(workspaceID) + laneKeySeparator + string(lane)
+}
+
+// parseQueueKey returns the workspace and lane of a queue lane key, ok is
+// false for keys that are not a queue
+func parseQueueKey(key string) (workspaceID string, lane Lane, ok bool) {
+	rest, found := strings.CutPrefix(key, queueKeyPrefix+"{")
+	if !found {
+		return "", "", false
+	}
+
+	workspaceID, suffix, found := strings.Cut(rest, "}")
+	if !found || workspaceID == "" {
+		return "", "", false
+	}
+
+	if suffix == "" {
+		return workspaceID, LaneRegular, true
+	}
+
+	name, found := strings.CutPrefix(suffix, laneKeySeparator)
+	lane = Lane(name)
+	if !found || !isValidLane(lane) || lane == LaneRegular {
+		return "", "", false
+	}
+
+	return workspaceID, lane, true
+}
+
+// callsInProgressKey returns the sorted set of call leases of a workspace
+func callsInProgressKey(workspaceID string) string {
+	return workspaceKey(workspaceID, "calls_in_progress")
+}
+
+// workspaceKey returns a workspace scoped key that is not a queue
+func workspaceKey(workspaceID, name string) string {
+	return fmt.Sprintf("workspace_%s_%s", hashTag(workspaceID), name)
+}
+
+// workspaceRateKey returns the cached max rate of a workspace
+func workspaceRateKey(workspaceID string) string {
+	return workspaceKey(workspaceID, "max_rate")
+}
+
+// workspaceBucketKey returns the token bucket of a workspace
+func workspaceBucketKey(workspaceID string) string {
+	return workspaceKey(workspaceID, "bucket")
+}
+
+// inFlightKey returns the hash of leads dequeued and not yet dispositioned
+func inFlightKey(workspaceID string) string {
+	return workspaceKey(workspaceID, "inflight")
+}
+
+// signalKey returns the list blocking dequeues wait on for new leads
+func signalKey(workspaceID string) string {
+	return workspaceKey(workspaceID, "signal")
+}
+
+// pausedCampaignsKey returns the set of paused campaigns of a workspace
+func pausedCampaignsKey(workspaceID string) string {
+	return workspaceKey(workspaceID, "paused_campaigns")
+}
+
+// parkedKey returns the list holding the leads of paused campaigns taken out
+// of a workspace queue lane
+func parkedKey(workspaceID string, lane Lane) string {
+	return workspaceKey(workspaceID, "parked_"+string(lane))
+}
+
+// callbackKey marks a callback of a workspace as queued
+func callbackKey(workspaceID, callbackID string) string {
+	return workspaceKey(workspaceID, "callback_"+callbackID)
+}
+
+// deadLetterKey returns the dead letter list of a workspace
+func deadLetterKey(workspaceID string) string {
+	return workspaceKey(workspaceID, "dead_letter")
+}
+
+// availableAgentsKey returns the number of agents of a workspace ready for a call
+func availableAgentsKey(workspaceID string) string {
+	return workspaceKey(workspaceID, "available_agents")
+}
+
+// pacingBucketKey returns the per-minute stats bucket of a workspace
+func pacingBucketKey(workspaceID string, minute time.Time) string {
+	return workspaceKey(workspaceID, fmt.Sprintf("stats_%d", minute.Unix()/60))
+}
+
+// campaignRatePrefix starts the rate keys of the campaigns of a workspace
+func campaignRatePrefix(workspaceID string) string {
+	return campaignKeyPrefix + hashTag(workspaceID) + "_"
+}
+
+// campaignRateKey returns the cached max rate of a campaign, tagged with its
+// workspace so the dequeue script can read it with the workspace queue
+func campaignRateKey(workspaceID, campaignID string) string {
+	return campaignRatePrefix(workspaceID) + campaignID + "_max_rate"
+}
+
+// campaignBucketKey returns the token bucket of a campaign, tagged with its workspace
+func campaignBucketKey(workspaceID, campaignID string) string {
+	return campaignRatePrefix(workspaceID) + campaignID + "_bucket"
+}
+
+// ratedCampaignsKey returns the set of campaigns of a workspace with a cached rate
+func ratedCampaignsKey(workspaceID string) string {
+	return workspaceKey(workspaceID, "rated_campaigns")
+}
+
+// campaignKey returns a campaign scoped key
+func campaignKey(campaignID, name string) string {
+	return campaignKeyPrefix + campaignID + "_" + name
+}
+
+// dispositionBucketKey returns the daily disposition bucket of a campaign
+func dispositionBucketKey(campaignID string, day time.Time) string {
+	return campaignKey(campaignID, "dispositions_"+day.Format("20060102"))
+}
+
+// complianceKey returns the last computed compliance state of a campaign
+func complianceKey(campaignID string) string {
+	return campaignKey(campaignID, "compliance")
+}
+
+// listDailyCountKey returns the number of leads injected from a list of a
+// workspace on a day
+func listDailyCountKey(workspaceID, listNumber string, day time.Time) string {
+	return workspaceKey(workspaceID, fmt.Sprintf("list_%s_calls_%s", listNumber, day.Format("20060102")))
+}
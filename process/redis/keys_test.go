@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseQueueKey tests queue lane keys round trip through parseQueueKey
+func TestParseQueueKey(t *testing.T) {
+	for _, lane := range queueLanes {
+		workspaceID, parsed, ok := parseQueueKey(laneKey("ws1", lane))
+		assert.True(t, ok)
+		assert.Equal(t, "ws1", workspaceID)
+		assert.Equal(t, lane, parsed)
+	}
+}
+
+// TestParseQueueKeyRejectsOtherKeys tests non queue keys are not parsed as queues
+func TestParseQueueKeyRejectsOtherKeys(t *testing.T) {
+	for _, key := range []string{
+		callsInProgressKey("ws1"),
+		signalKey("ws1"),
+		campaignRateKey("ws1", "c1"),
+		activeWorkspacesKey,
+		"ws_",
+		"ws_{}",
+		"ws_{ws1}_lane_regular",
+	} {
+		_, _, ok := parseQueueKey(key)
+		assert.False(t, ok, key)
+	}
+}
+
+// TestWorkspaceKeysShareHashTag tests every key of a workspace carries the same hash tag
+func TestWorkspaceKeysShareHashTag(t *testing.T) {
+	keys := append(laneKeys("ws1"),
+		callsInProgressKey("ws1"),
+		workspaceRateKey("ws1"),
+		workspaceBucketKey("ws1"),
+		inFlightKey("ws1"),
+		signalKey("ws1"),
+		campaignRateKey("ws1", "c1"),
+		campaignBucketKey("ws1", "c1"),
+		ratedCampaignsKey("ws1"),
+		pausedCampaignsKey("ws1"),
+		parkedKey("ws1", LaneRegular),
+		listDailyCountKey("ws1", "l1", time.Now()),
+		callbackKey("ws1", "cb1"),
+	)
+
+	for _, key := range keys {
+		assert.Contains(t, key, "{ws1}")
+	}
+}
@@ -0,0 +1,52 @@
+package redis
+
+// Lane is a priority level of a workspace queue. Every lane is a list of its
+// own and dialers drain them in the order of queueLanes, so a lead in a
+// higher lane is always dequeued before the regular injections.
+type Lane string
+
+const (
+	// LaneDialNow holds leads a manager asked to dial immediately
+	LaneDialNow Lane = "now"
+	// LaneCallback holds scheduled callbacks that are due
+	LaneCallback Lane = "callback"
+	// LaneRetry holds leads that are dialed again after a failed attempt
+	LaneRetry Lane = "retry"
+	// LaneRegular holds the leads injected by the hopper
+	LaneRegular Lane = "regular"
+)
+
+// queueLanes lists the lanes from highest to lowest priority
+var queueLanes = []Lane{LaneDialNow, LaneCallback, LaneRetry, LaneRegular}
+
+// laneKeys returns the list keys of every lane of a workspace queue, highest priority first
+func laneKeys(workspaceID string) []string {
+	keys := make([]string, len(queueLanes))
+	for i, lane := range queueLanes {
+		keys[i] = laneKey(workspaceID, lane)
+	}
+
+	return keys
+}
+
+// parkedKeys returns the parked lists of every lane of a workspace queue,
+// in the order of laneKeys
+func parkedKeys(workspaceID string) []string {
+	keys := make([]string, len(queueLanes))
+	for i, lane := range queueLanes {
+		keys[i] = parkedKey(workspaceID, lane)
+	}
+
+	return keys
+}
+
+// isValidLane reports whether lane is one of the queue lanes
+func isValidLane(lane Lane) bool {
+	for _, l := range queueLanes {
+		if l == lane {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,192 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nico-phil/process/logging"
+	"github.com/redis/go-redis/v9"
+)
+
+// legacyCallCountPattern matches the calls in progress keys of earlier
+// releases: plain string counters named ws_<id>_calls_in_progress, then
+// sorted sets of leases named ws_{<id>}_calls_in_progress
+const legacyCallCountPattern = queueKeyPrefix + "*" + legacyCallsInProgressSuffix
+
+// MigrateKeys moves or removes the keys earlier releases wrote under names
+// or types the process no longer reads. It is safe to run on every start and
+// returns how many keys it changed.
+func MigrateKeys(ctx context.Context) (int, error) {
+	callKeys, err := scanLegacyKeys(ctx, legacyCallCountPattern)
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, key := range callKeys {
+		ok, err := migrateCallsInProgress(ctx, key)
+		if err != nil {
+			return migrated, err
+		}
+		if ok {
+			migrated++
+		}
+	}
+
+	// queues were named ws_<id> before they carried a hash tag
+	queueKeys, err := scanLegacyKeys(ctx, queueKeyPrefix+"*")
+	if err != nil {
+		return migrated, err
+	}
+
+	for _, key := range queueKeys {
+		workspaceID, ok := parseLegacyQueueKey(key)
+		if !ok {
+			continue
+		}
+
+		ok, err := moveLegacyQueue(ctx, key, workspaceID)
+		if err != nil {
+			return migrated, err
+		}
+		if ok {
+			migrated++
+		}
+	}
+
+	return migrated, nil
+}
+
+// scanLegacyKeys returns the keys matching pattern, collected before any of
+// them is changed
+func scanLegacyKeys(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+	err := scanKeys(ctx, pattern, func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan legacy keys %s: %v", pattern, err)
+	}
+
+	return keys, nil
+}
+
+// migrateCallsInProgress migrates a legacy calls in progress key. Calls in
+// progress were a counter, dropped as dialers rebuild the leases as they start
+// calls, then a sorted set named like a queue, merged into the current key.
+func migrateCallsInProgress(ctx context.Context, key string) (bool, error) {
+	keyType, err := rdb.Type(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to migrate %s: %v", key, err)
+	}
+
+	switch keyType {
+	case "string":
+		if err := rdb.Del(ctx, key).Err(); err != nil {
+			return false, fmt.Errorf("failed to delete legacy call counter %s: %v", key, err)
+		}
+
+		logger.Info("deleted legacy call counter", "key", key)
+		return true, nil
+	case "zset":
+		workspaceID, ok := parseLegacyCallsInProgressKey(key)
+		if !ok {
+			return false, nil
+		}
+
+		if err := moveCallLeases(ctx, key, callsInProgressKey(workspaceID)); err != nil {
+			return false, err
+		}
+
+		logger.Info("moved legacy call leases", "key", key, logging.WorkspaceID, workspaceID)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// moveLegacyQueue appends the leads of a legacy queue list to the regular
+// lane of its workspace, keeping their order, and deletes the legacy list.
+// The keys are in different cluster slots, so the move is not atomic: a
+// crash in between queues the leads twice, never loses them.
+func moveLegacyQueue(ctx context.Context, key, workspaceID string) (bool, error) {
+	keyType, err := rdb.Type(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to migrate %s: %v", key, err)
+	}
+
+	if keyType != "list" {
+		return false, nil
+	}
+
+	entries, err := rdb.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to read legacy queue %s: %v", key, err)
+	}
+
+	if len(entries) > 0 {
+		values := make([]interface{}, len(entries))
+		for i, entry := range entries {
+			values[i] = entry
+		}
+
+		if err := rdb.RPush(ctx, laneKey(workspaceID, LaneRegular), values...).Err(); err != nil {
+			return false, fmt.Errorf("failed to move legacy queue %s: %v", key, err)
+		}
+
+		if err := rdb.SAdd(ctx, activeWorkspacesKey, workspaceID).Err(); err != nil {
+			return false, fmt.Errorf("failed to register workspace %s: %v", workspaceID, err)
+		}
+	}
+
+	if err := rdb.Del(ctx, key).Err(); err != nil {
+		return false, fmt.Errorf("failed to delete legacy queue %s: %v", key, err)
+	}
+
+	logger.Info("moved legacy queue", "key", key, logging.WorkspaceID, workspaceID, "count", len(entries))
+	return true, nil
+}
+
+// parseLegacyQueueKey returns the workspace of a ws_<id> queue key, written
+// without a hash tag
+func parseLegacyQueueKey(key string) (string, bool) {
+	workspaceID, found := strings.CutPrefix(key, queueKeyPrefix)
+	if !found || workspaceID == "" || strings.HasPrefix(workspaceID, "{") || strings.HasSuffix(workspaceID, legacyCallsInProgressSuffix) {
+		return "", false
+	}
+
+	return workspaceID, true
+}
+
+// parseLegacyCallsInProgressKey returns the workspace of a ws_{<id>}_calls_in_progress key
+func parseLegacyCallsInProgressKey(key string) (string, bool) {
+	rest, found := strings.CutPrefix(key, queueKeyPrefix+"{")
+	if !found {
+		return "", false
+	}
+
+	workspaceID, found := strings.CutSuffix(rest, "}"+legacyCallsInProgressSuffix)
+	if !found || workspaceID == "" {
+		return "", false
+	}
+
+	return workspaceID, true
+}
+
+// moveCallLeases merges the call leases of a legacy key into the current one,
+// keeping the latest expiry of a call found in both, and deletes the legacy
+// key. Both keys carry the same hash tag.
+func moveCallLeases(ctx context.Context, legacyKey, key string) error {
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZUnionStore(ctx, key, &redis.ZStore{Keys: []string{key, legacyKey}, Aggregate: "MAX"})
+		pipe.Del(ctx, legacyKey)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move call leases of %s: %v", legacyKey, err)
+	}
+
+	return nil
+}
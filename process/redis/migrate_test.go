@@ -0,0 +1,43 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseLegacyCallsInProgressKey tests the workspace is read from the
+// calls in progress keys of earlier releases only
+func TestParseLegacyCallsInProgressKey(t *testing.T) {
+	workspaceID, ok := parseLegacyCallsInProgressKey("ws_{ws1}_calls_in_progress")
+	assert.True(t, ok)
+	assert.Equal(t, "ws1", workspaceID)
+
+	for _, key := range []string{
+		callsInProgressKey("ws1"),
+		"ws_ws1_calls_in_progress",
+		"ws_{}_calls_in_progress",
+		laneKey("ws1", LaneRegular),
+	} {
+		_, ok := parseLegacyCallsInProgressKey(key)
+		assert.False(t, ok, key)
+	}
+}
+
+// TestParseLegacyQueueKey tests only queue keys written without a hash tag
+// are read as legacy queues
+func TestParseLegacyQueueKey(t *testing.T) {
+	workspaceID, ok := parseLegacyQueueKey("ws_ws1")
+	assert.True(t, ok)
+	assert.Equal(t, "ws1", workspaceID)
+
+	for _, key := range []string{
+		laneKey("ws1", LaneRegular),
+		laneKey("ws1", LaneRetry),
+		"ws_ws1_calls_in_progress",
+		"ws_",
+	} {
+		_, ok := parseLegacyQueueKey(key)
+		assert.False(t, ok, key)
+	}
+}
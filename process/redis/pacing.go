@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PacingStats aggregates call outcomes of a workspace over a time window
+type PacingStats struct {
+	Dialed          int
+	Answered        int
+	TotalHandleTime time.Duration
+	AvailableAgents int
+}
+
+// RecordCallOutcome adds a finished call to the workspace pacing stats
+func RecordCallOutcome(workspaceID string, answered bool, handleTime time.Duration) error {
+	key := pacingBucketKey(workspaceID, time.Now())
+
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, "dialed", 1)
+		if answered {
+			pipe.HIncrBy(ctx, key, "answered", 1)
+			pipe.HIncrBy(ctx, key, "handle_ms", handleTime.Milliseconds())
+		}
+		pipe.Expire(ctx, key, 2*time.Hour)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record call outcome for workspace %s: %v", workspaceID, err)
+	}
+
+	return nil
+}
+
+// SetAvailableAgents stores the number of agents ready to take a call
+func SetAvailableAgents(workspaceID string, agents int) error {
+	key := availableAgentsKey(workspaceID)
+	err := rdb.Set(ctx, key, agents, 5*time.Minute).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set available agents for workspace %s: %v", workspaceID, err)
+	}
+
+	return nil
+}
+
+// GetPacingStats sums the workspace stats buckets of the last window
+func GetPacingStats(ctx context.Context, workspaceID string, window time.Duration) (*PacingStats, error) {
+	now := time.Now()
+	minutes := int(window.Minutes())
+	if minutes < 1 {
+		minutes = 1
+	}
+
+	buckets := make([]*redis.MapStringStringCmd, 0, minutes)
+	var agents *redis.StringCmd
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := 0; i < minutes; i++ {
+			key := pacingBucketKey(workspaceID, now.Add(-time.Duration(i)*time.Minute))
+			buckets = append(buckets, pipe.HGetAll(ctx, key))
+		}
+		agents = pipe.Get(ctx, availableAgentsKey(workspaceID))
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get pacing stats for workspace %s: %v", workspaceID, err)
+	}
+
+	stats := &PacingStats{}
+	for _, bucket := range buckets {
+		values, err := bucket.Result()
+		if err != nil {
+			continue
+		}
+
+		dialed, _ := strconv.Atoi(values["dialed"])
+		answered, _ := strconv.Atoi(values["answered"])
+		handleMs, _ := strconv.ParseInt(values["handle_ms"], 10, 64)
+
+		stats.Dialed += dialed
+		stats.Answered += answered
+		stats.TotalHandleTime += time.Duration(handleMs) * time.Millisecond
+	}
+
+	stats.AvailableAgents, _ = agents.Int()
+
+	return stats, nil
+}
@@ -0,0 +1,192 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nico-phil/process/logging"
+	"github.com/redis/go-redis/v9"
+)
+
+// moveScript moves a payload from a list to another if it is still there,
+// so a lead popped by a dialer in the meantime is not moved back
+//
+// KEYS[1] source list
+// KEYS[2] destination list
+// ARGV[1] payload
+// ARGV[2] LPUSH or RPUSH, the end of the destination the payload goes to
+//
+// Returns 1 when the payload was moved
+var moveScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], -1, ARGV[1]) > 0 then
+	redis.call(ARGV[2], KEYS[2], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// PauseCampaign marks a campaign of a workspace as paused and parks its
+// queued leads. From then on the dequeue script parks the leads of the
+// campaign instead of handing them to a dialer. It returns how many leads
+// were parked.
+func PauseCampaign(ctx context.Context, workspaceID, campaignID string) (int, error) {
+	if err := rdb.SAdd(ctx, pausedCampaignsKey(workspaceID), campaignID).Err(); err != nil {
+		return 0, fmt.Errorf("failed to pause campaign %s: %v", campaignID, err)
+	}
+
+	parked := 0
+	for _, lane := range queueLanes {
+		// oldest first, so parked leads keep their queue order
+		moved, err := moveCampaignLeads(ctx, laneKey(workspaceID, lane), parkedKey(workspaceID, lane), campaignID, true, "LPUSH")
+		parked += moved
+		if err != nil {
+			return parked, fmt.Errorf("failed to park leads of campaign %s: %v", campaignID, err)
+		}
+	}
+
+	logger.Info("paused campaign", logging.WorkspaceID, workspaceID, logging.CampaignID, campaignID, "parked", parked)
+	return parked, nil
+}
+
+// ResumeCampaign clears the paused flag of a campaign and puts its parked
+// leads back in their lane, ahead of the leads queued while it was paused.
+// It returns how many leads were queued again.
+func ResumeCampaign(ctx context.Context, workspaceID, campaignID string) (int, error) {
+	if err := rdb.SRem(ctx, pausedCampaignsKey(workspaceID), campaignID).Err(); err != nil {
+		return 0, fmt.Errorf("failed to resume campaign %s: %v", campaignID, err)
+	}
+
+	queued := 0
+	for _, lane := range queueLanes {
+		// newest first to the dequeue end, so the oldest lead is dialed first
+		moved, err := moveCampaignLeads(ctx, parkedKey(workspaceID, lane), laneKey(workspaceID, lane), campaignID, false, "RPUSH")
+		queued += moved
+		if err != nil {
+			return queued, fmt.Errorf("failed to queue parked leads of campaign %s: %v", campaignID, err)
+		}
+	}
+
+	if queued > 0 {
+		_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// wake up a dialer blocked in BlockingDequeueLead
+			pipe.LPush(ctx, signalKey(workspaceID), 1)
+			pipe.LTrim(ctx, signalKey(workspaceID), 0, 999)
+			pipe.Expire(ctx, signalKey(workspaceID), time.Hour)
+			return nil
+		})
+		if err != nil {
+			logger.Error("failed to signal resumed leads", logging.WorkspaceID, workspaceID, "error", err)
+		}
+
+		if err := rdb.SAdd(ctx, activeWorkspacesKey, workspaceID).Err(); err != nil {
+			logger.Error("failed to register workspace", logging.WorkspaceID, workspaceID, "error", err)
+		}
+	}
+
+	logger.Info("resumed campaign", logging.WorkspaceID, workspaceID, logging.CampaignID, campaignID, "queued", queued)
+	return queued, nil
+}
+
+// IsCampaignPaused reports whether a campaign of a workspace is paused
+func IsCampaignPaused(ctx context.Context, workspaceID, campaignID string) (bool, error) {
+	paused, err := rdb.SIsMember(ctx, pausedCampaignsKey(workspaceID), campaignID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check campaign %s: %v", campaignID, err)
+	}
+
+	return paused, nil
+}
+
+// GetPausedCampaigns returns the paused campaigns of a workspace
+func GetPausedCampaigns(ctx context.Context, workspaceID string) (map[string]bool, error) {
+	campaignIDs, err := rdb.SMembers(ctx, pausedCampaignsKey(workspaceID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paused campaigns for workspace %s: %v", workspaceID, err)
+	}
+
+	paused := make(map[string]bool, len(campaignIDs))
+	for _, campaignID := range campaignIDs {
+		paused[campaignID] = true
+	}
+
+	return paused, nil
+}
+
+// RemoveParkedLeads removes the parked leads of a workspace matching fn and
+// returns them
+func RemoveParkedLeads(ctx context.Context, workspaceID string, fn func(QueuedLead) bool) ([]QueuedLead, error) {
+	var removed []QueuedLead
+	for _, key := range parkedKeys(workspaceID) {
+		leads, err := matchingLeads(ctx, key, fn)
+		if err != nil {
+			return removed, err
+		}
+
+		for _, lead := range leads {
+			count, err := rdb.LRem(ctx, key, 1, lead.payload).Result()
+			if err != nil {
+				return removed, fmt.Errorf("failed to remove parked lead %s for workspace %s: %v", lead.LeadID, workspaceID, err)
+			}
+
+			if count > 0 {
+				removed = append(removed, lead.QueuedLead)
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// rawLead is a queued lead with the payload it was read from
+type rawLead struct {
+	QueuedLead
+	payload string
+}
+
+// matchingLeads returns the leads of a list matching fn, newest first
+func matchingLeads(ctx context.Context, key string, fn func(QueuedLead) bool) ([]rawLead, error) {
+	payloads, err := rdb.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", key, err)
+	}
+
+	var leads []rawLead
+	for _, payload := range payloads {
+		var lead QueuedLead
+		if err := json.Unmarshal([]byte(payload), &lead); err != nil || !fn(lead) {
+			continue
+		}
+
+		leads = append(leads, rawLead{QueuedLead: lead, payload: payload})
+	}
+
+	return leads, nil
+}
+
+// moveCampaignLeads moves the leads of a campaign from a list to another with
+// push, newest first or oldest first, and returns how many were moved
+func moveCampaignLeads(ctx context.Context, from, to, campaignID string, oldestFirst bool, push string) (int, error) {
+	leads, err := matchingLeads(ctx, from, func(lead QueuedLead) bool { return lead.CampaignID == campaignID })
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for i := range leads {
+		lead := leads[i]
+		if oldestFirst {
+			lead = leads[len(leads)-1-i]
+		}
+
+		ok, err := moveScript.Run(ctx, rdb, []string{from, to}, lead.payload, push).Int()
+		if err != nil {
+			return moved, err
+		}
+
+		moved += ok
+	}
+
+	return moved, nil
+}
@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPauseCampaign tests the leads of a paused campaign are parked, even
+// those queued after the pause, and queued again in order on resume
+func TestPauseCampaign(t *testing.T) {
+	useMiniredis(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	queue := func(leadID, campaignID string) {
+		require.NoError(t, QueueLead(ctx, "ws1", QueuedLead{LeadID: leadID, CampaignID: campaignID, QueuedAt: now}))
+	}
+	queue("a1", "c1")
+	queue("b1", "c2")
+	queue("a2", "c1")
+
+	parked, err := PauseCampaign(ctx, "ws1", "c1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, parked)
+
+	paused, err := GetPausedCampaigns(ctx, "ws1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"c1": true}, paused)
+
+	// queued after the pause, parked by the dequeue script
+	queue("a3", "c1")
+	leads, err := DequeueLeads("ws1", 3)
+	require.NoError(t, err)
+	require.Len(t, leads, 1)
+	assert.Equal(t, "b1", leads[0].LeadID)
+
+	queued, err := ResumeCampaign(ctx, "ws1", "c1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, queued)
+
+	leads, err = DequeueLeads("ws1", 3)
+	require.NoError(t, err)
+
+	var ids []string
+	for _, lead := range leads {
+		ids = append(ids, lead.LeadID)
+	}
+	assert.Equal(t, []string{"a1", "a2", "a3"}, ids)
+
+	paused, err = GetPausedCampaigns(ctx, "ws1")
+	require.NoError(t, err)
+	assert.Empty(t, paused)
+}
@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CompleteInFlightLead removes a dequeued lead from the in flight leads once
+// its call has been dispositioned
+func CompleteInFlightLead(workspaceID, leadID string) error {
+	key := inFlightKey(workspaceID)
+	if err := rdb.HDel(ctx, key, leadID).Err(); err != nil {
+		return fmt.Errorf("failed to complete in flight lead %s for workspace %s: %v", leadID, workspaceID, err)
+	}
+
+	return nil
+}
+
+// renewInFlightScript stamps an in flight lead with the current time, only
+// when it is still in flight, so a late heartbeat does not bring back a lead
+// whose call ended.
+//
+// KEYS[1] workspace in flight leads
+// ARGV[1] lead id
+// ARGV[2] current time in milliseconds
+var renewInFlightScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// RenewInFlightLead pushes back the age of a lead whose call is still in
+// progress, so a call longer than the in flight max age is not taken for a
+// dialer that never reported back
+func RenewInFlightLead(workspaceID, leadID string) error {
+	err := renewInFlightScript.Run(ctx, rdb, []string{inFlightKey(workspaceID)}, leadID, time.Now().UnixMilli()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to renew in flight lead %s for workspace %s: %v", leadID, workspaceID, err)
+	}
+
+	return nil
+}
+
+// GetInFlightLeadIDs returns the leads dequeued by a dialer within maxAge.
+// Older entries belong to dialers that never reported back and are dropped.
+func GetInFlightLeadIDs(workspaceID string, maxAge time.Duration) (map[string]bool, error) {
+	key := inFlightKey(workspaceID)
+	entries, err := rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get in flight leads for workspace %s: %v", workspaceID, err)
+	}
+
+	cutoff := time.Now().Add(-maxAge).UnixMilli()
+	leadIDs := map[string]bool{}
+	var stale []string
+	for leadID, dequeuedAt := range entries {
+		ts, _ := strconv.ParseInt(dequeuedAt, 10, 64)
+		if ts < cutoff {
+			stale = append(stale, leadID)
+			continue
+		}
+
+		leadIDs[leadID] = true
+	}
+
+	if len(stale) > 0 {
+		rdb.HDel(ctx, key, stale...)
+	}
+
+	return leadIDs, nil
+}
+
+// GetQueuedLeadIDs returns the IDs of every lead waiting in a workspace
+// queue, in any lane, parked leads of paused campaigns and dead letters
+// included
+func GetQueuedLeadIDs(workspaceID string) (map[string]bool, error) {
+	leadIDs := map[string]bool{}
+	for _, key := range append(laneKeys(workspaceID), parkedKeys(workspaceID)...) {
+		payloads, err := rdb.LRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queue for workspace %s: %v", workspaceID, err)
+		}
+
+		for _, payload := range payloads {
+			var lead QueuedLead
+			if err := json.Unmarshal([]byte(payload), &lead); err != nil {
+				continue
+			}
+
+			leadIDs[lead.LeadID] = true
+		}
+	}
+
+	deadLetters, err := rdb.LRange(ctx, deadLetterKey(workspaceID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letters for workspace %s: %v", workspaceID, err)
+	}
+
+	for _, entry := range deadLetters {
+		var deadLetter DeadLetter
+		var lead QueuedLead
+		if json.Unmarshal([]byte(entry), &deadLetter) != nil || json.Unmarshal([]byte(deadLetter.Payload), &lead) != nil {
+			continue
+		}
+
+		leadIDs[lead.LeadID] = true
+	}
+
+	return leadIDs, nil
+}
@@ -0,0 +1,26 @@
+package redis
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRenewInFlightLead tests a heartbeat keeps a lead in flight past the max
+// age and does not bring back a completed lead
+func TestRenewInFlightLead(t *testing.T) {
+	m := useMiniredis(t)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).UnixMilli(), 10)
+	m.HSet(inFlightKey("ws1"), "l1", stale, "l2", stale)
+
+	require.NoError(t, RenewInFlightLead("ws1", "l1"))
+	require.NoError(t, CompleteInFlightLead("ws1", "l2"))
+	require.NoError(t, RenewInFlightLead("ws1", "l2"))
+
+	leadIDs, err := GetInFlightLeadIDs("ws1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"l1": true}, leadIDs)
+}
@@ -0,0 +1,300 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nico-phil/process/config"
+	"github.com/nico-phil/process/logging"
+	"github.com/redis/go-redis/v9"
+)
+
+// dequeueScanDepth is how many of the oldest leads of each lane the dequeue
+// script looks at to find one whose campaign has a token left
+const dequeueScanDepth = 100
+
+// dequeueScript pops up to ARGV[4] leads from the highest non empty lanes of
+// a workspace queue, taking one token per lead from both the workspace bucket
+// and the bucket of the lead's campaign. Popping stops when the workspace
+// bucket is empty. A lead whose campaign bucket is empty stays queued and the
+// script moves on to the next oldest lead of another campaign, looking at the
+// ARGV[5] oldest leads of each lane, so one throttled campaign does not hold
+// back the others. Popped leads are recorded as in flight until their call
+// is dispositioned. Buckets refill continuously at max_rate tokens per minute
+// and hold at most one minute of tokens. A bucket without a cached rate is
+// unlimited, and so is a campaign whose keys are not passed. Leads of paused
+// campaigns are moved to the parked list of their lane instead of being
+// popped, they take no token, and so are expired leads, which the expiry
+// sweep then sets back to dialable. A lead without expires_at expires after
+// the queued lead TTL. Every key the script touches is passed in KEYS.
+//
+// KEYS[1..n] workspace queue lanes, highest priority first
+// KEYS[n+1] workspace max rate
+// KEYS[n+2] workspace bucket
+// KEYS[n+3] workspace in flight leads
+// KEYS[n+4] workspace paused campaigns
+// KEYS[n+5..2n+4] parked lists, in the order of the lanes
+// KEYS[2n+3+2k], KEYS[2n+4+2k] max rate and bucket of the campaign ARGV[5+k]
+// ARGV[1] current time in milliseconds
+// ARGV[2] number of lanes n
+// ARGV[3] maximum number of leads to pop
+// ARGV[4] number of leads looked at per lane
+// ARGV[5] queued lead TTL in milliseconds
+// ARGV[6..] ids of the campaigns with a rate
+//
+// Returns {wait_ms, parked, expired, lead...}. wait_ms is 0 unless popping
+// stopped on an empty bucket, then it is the time until the next token of the
+// workspace, or of the first throttled campaign to refill. parked and expired
+// are the number of leads of paused campaigns and of expired leads moved to a
+// parked list.
+var dequeueScript = redis.NewScript(`
+local function take(rate_key, bucket_key, now)
+	local rate = tonumber(redis.call('GET', rate_key))
+	if not rate or rate <= 0 then
+		return 0, nil
+	end
+
+	local state = redis.call('HMGET', bucket_key, 'tokens', 'ts')
+	local tokens = tonumber(state[1]) or rate
+	local ts = tonumber(state[2]) or now
+
+	tokens = math.min(rate, tokens + (now - ts) * rate / 60000)
+	if tokens < 1 then
+		return math.ceil((1 - tokens) * 60000 / rate), nil
+	end
+
+	return 0, {bucket_key, tokens - 1, now}
+end
+
+-- to_millis reads a time encoded by encoding/json, RFC 3339 with an
+-- optional fraction, as milliseconds since the epoch. The zero time and
+-- anything else read as nil.
+local function to_millis(value)
+	if type(value) ~= 'string' then
+		return nil
+	end
+
+	local y, mo, d, h, mi, s, frac, zone = string.match(value, '^(%d+)-(%d+)-(%d+)T(%d+):(%d+):(%d+)(%.?%d*)(.*)$')
+	if not y then
+		return nil
+	end
+
+	y, mo, d = tonumber(y), tonumber(mo), tonumber(d)
+	if y <= 1 then
+		return nil
+	end
+
+	-- days since the epoch of the civil date, March based years
+	if mo <= 2 then
+		y = y - 1
+	end
+	local era = math.floor(y / 400)
+	local yoe = y - era * 400
+	local doy = math.floor((153 * ((mo + 9) % 12) + 2) / 5) + d - 1
+	local days = era * 146097 + yoe * 365 + math.floor(yoe / 4) - math.floor(yoe / 100) + doy - 719468
+
+	local secs = days * 86400 + tonumber(h) * 3600 + tonumber(mi) * 60 + tonumber(s)
+	if zone ~= 'Z' then
+		local sign, zh, zm = string.match(zone, '^([+-])(%d%d):(%d%d)$')
+		if not sign then
+			return nil
+		end
+
+		local offset = tonumber(zh) * 3600 + tonumber(zm) * 60
+		if sign == '+' then
+			secs = secs - offset
+		else
+			secs = secs + offset
+		end
+	end
+
+	local ms = 0
+	if frac ~= '' and frac ~= '.' then
+		ms = math.floor(tonumber('0' .. frac) * 1000)
+	end
+
+	return secs * 1000 + ms
+end
+
+local function expired(decoded, now, ttl)
+	local expires_at = to_millis(decoded['expires_at'])
+	if not expires_at then
+		local queued_at = to_millis(decoded['queued_at'])
+		if not queued_at then
+			return false
+		end
+		expires_at = queued_at + ttl
+	end
+
+	return now > expires_at
+end
+
+local function store(update)
+	if update then
+		redis.call('HSET', update[1], 'tokens', tostring(update[2]), 'ts', update[3])
+		redis.call('PEXPIRE', update[1], 120000)
+	end
+end
+
+local lanes = tonumber(ARGV[2])
+local now = tonumber(ARGV[1])
+local depth = tonumber(ARGV[4])
+local ttl = tonumber(ARGV[5])
+local result = {0, 0, 0}
+
+local campaign_keys = {}
+for k = 1, #ARGV - 5 do
+	campaign_keys[ARGV[5 + k]] = {KEYS[2 * lanes + 3 + 2 * k], KEYS[2 * lanes + 4 + 2 * k]}
+end
+local popped = 0
+local throttled = {}
+local campaign_wait = 0
+
+while popped < tonumber(ARGV[3]) do
+	local wait, workspace_update = take(KEYS[lanes + 1], KEYS[lanes + 2], now)
+	if wait > 0 then
+		result[1] = wait
+		break
+	end
+
+	local found = false
+	for i = 1, lanes do
+		local leads = redis.call('LRANGE', KEYS[i], -depth, -1)
+		-- the tail of a lane is its oldest lead
+		for j = #leads, 1, -1 do
+			local lead = leads[j]
+			local ok, decoded = pcall(cjson.decode, lead)
+			local campaign = nil
+			if ok and type(decoded) == 'table' and type(decoded['campaign_id']) == 'string' then
+				campaign = decoded['campaign_id']
+			end
+
+			if campaign and redis.call('SISMEMBER', KEYS[lanes + 4], campaign) == 1 then
+				redis.call('LREM', KEYS[i], -1, lead)
+				redis.call('LPUSH', KEYS[lanes + 4 + i], lead)
+				result[2] = result[2] + 1
+			elseif ok and type(decoded) == 'table' and expired(decoded, now, ttl) then
+				redis.call('LREM', KEYS[i], -1, lead)
+				redis.call('LPUSH', KEYS[lanes + 4 + i], lead)
+				result[3] = result[3] + 1
+			elseif not (campaign and throttled[campaign]) then
+				local campaign_update = nil
+				if campaign and campaign_keys[campaign] then
+					local keys = campaign_keys[campaign]
+					wait, campaign_update = take(keys[1], keys[2], now)
+					if wait > 0 then
+						throttled[campaign] = true
+						if campaign_wait == 0 or wait < campaign_wait then
+							campaign_wait = wait
+						end
+					end
+				end
+
+				if not (campaign and throttled[campaign]) then
+					store(workspace_update)
+					store(campaign_update)
+
+					redis.call('LREM', KEYS[i], -1, lead)
+					if ok and type(decoded) == 'table' and type(decoded['lead_id']) == 'string' then
+						redis.call('HSET', KEYS[lanes + 3], decoded['lead_id'], now)
+					end
+					table.insert(result, lead)
+					popped = popped + 1
+					found = true
+					break
+				end
+			end
+		end
+
+		if found then
+			break
+		end
+	end
+
+	if not found then
+		result[1] = campaign_wait
+		break
+	end
+end
+
+return result
+`)
+
+// RateLimitedError is returned by DequeueLead when the workspace or campaign
+// bucket is empty. RetryAfter tells the dialer when a token will be available.
+type RateLimitedError struct {
+	WorkspaceID string
+	RetryAfter  time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limit reached for workspace %s, retry after %v", e.WorkspaceID, e.RetryAfter)
+}
+
+// rateCacheTicks is how many hopper ticks a cached rate outlives its cycle.
+// A bucket without a rate is unlimited, so a rate must survive a slow or
+// briefly stopped hopper, but not stay enforced once its campaign is gone.
+const rateCacheTicks = 10
+
+// rateCacheTTL returns how long a cached rate lives
+func rateCacheTTL() time.Duration {
+	return rateCacheTicks * config.GetTickInterval()
+}
+
+// CacheWorkspaceRate caches the workspace max rate per minute for
+// rateCacheTTL. It is replaced by the next cycle.
+func CacheWorkspaceRate(ctx context.Context, workspaceID string, maxRate int) error {
+	key := workspaceRateKey(workspaceID)
+	err := rdb.Set(ctx, key, maxRate, rateCacheTTL()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to cache workspace rate: %v", err)
+	}
+
+	logger.Debug("cached workspace max rate", logging.WorkspaceID, workspaceID, "max_rate", maxRate)
+	return nil
+}
+
+// popLeadsWithRateLimit runs the dequeue script for a workspace and returns
+// up to count raw lead payloads, and how long to wait when a bucket ran empty
+func popLeadsWithRateLimit(workspaceID string, count int) ([]string, time.Duration, error) {
+	keys := append(laneKeys(workspaceID),
+		workspaceRateKey(workspaceID),
+		workspaceBucketKey(workspaceID),
+		inFlightKey(workspaceID),
+		pausedCampaignsKey(workspaceID),
+	)
+	keys = append(keys, parkedKeys(workspaceID)...)
+
+	campaigns, err := rdb.SMembers(ctx, ratedCampaignsKey(workspaceID)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args := []interface{}{time.Now().UnixMilli(), len(queueLanes), count, dequeueScanDepth, config.GetQueuedLeadTTL().Milliseconds()}
+	for _, campaignID := range campaigns {
+		keys = append(keys, campaignRateKey(workspaceID, campaignID), campaignBucketKey(workspaceID, campaignID))
+		args = append(args, campaignID)
+	}
+
+	result, err := dequeueScript.Run(ctx, rdb, keys, args...).Slice()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	wait := time.Duration(result[0].(int64)) * time.Millisecond
+	if parked := result[1].(int64); parked > 0 {
+		logger.Info("parked leads of paused campaigns", logging.WorkspaceID, workspaceID, "count", parked)
+	}
+
+	if expired := result[2].(int64); expired > 0 {
+		logger.Info("parked expired leads", logging.WorkspaceID, workspaceID, "count", expired)
+	}
+
+	payloads := make([]string, 0, len(result)-3)
+	for _, payload := range result[3:] {
+		payloads = append(payloads, payload.(string))
+	}
+
+	return payloads, wait, nil
+}
@@ -0,0 +1,102 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useMiniredis points the package client at an in memory redis for the test
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	m := miniredis.RunT(t)
+	previous := rdb
+	rdb = redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() {
+		rdb.Close()
+		rdb = previous
+	})
+
+	return m
+}
+
+// TestDequeueParksExpiredLeads tests the dequeue script parks expired leads
+// instead of popping them, whatever the zone of their expiry
+func TestDequeueParksExpiredLeads(t *testing.T) {
+	m := useMiniredis(t)
+	ctx := context.Background()
+	now := time.Now()
+	newYork := time.FixedZone("EDT", -4*3600)
+
+	leads := []QueuedLead{
+		{LeadID: "window", CampaignID: "c1", QueuedAt: now.Add(-time.Minute), ExpiresAt: now.Add(-time.Second).In(newYork)},
+		{LeadID: "ttl", CampaignID: "c1", QueuedAt: now.Add(-time.Hour)},
+		{LeadID: "fresh", CampaignID: "c1", QueuedAt: now, ExpiresAt: now.Add(time.Minute).In(newYork)},
+		{LeadID: "no_expiry", CampaignID: "c1", QueuedAt: now},
+	}
+	for _, lead := range leads {
+		require.NoError(t, QueueLead(ctx, "ws1", lead))
+	}
+
+	popped, err := DequeueLeads("ws1", 4)
+	require.NoError(t, err)
+
+	var ids []string
+	for _, lead := range popped {
+		ids = append(ids, lead.LeadID)
+	}
+	assert.Equal(t, []string{"fresh", "no_expiry"}, ids)
+
+	parked, err := m.List(parkedKey("ws1", LaneRegular))
+	require.NoError(t, err)
+
+	var parkedIDs []string
+	for _, payload := range parked {
+		var lead QueuedLead
+		require.NoError(t, json.Unmarshal([]byte(payload), &lead))
+		parkedIDs = append(parkedIDs, lead.LeadID)
+	}
+	assert.ElementsMatch(t, []string{"window", "ttl"}, parkedIDs)
+}
+
+// TestDequeueThrottlesRatedCampaigns tests a campaign out of tokens is
+// skipped while the leads of other campaigns are still popped
+func TestDequeueThrottlesRatedCampaigns(t *testing.T) {
+	useMiniredis(t)
+	ctx := context.Background()
+
+	require.NoError(t, CacheCampaignRates(ctx, "ws1", map[string]int{"c1": 1}))
+	for _, id := range []string{"a1", "a2", "b1"} {
+		campaignID := "c1"
+		if id[0] == 'b' {
+			campaignID = "c2"
+		}
+		require.NoError(t, QueueLead(ctx, "ws1", QueuedLead{LeadID: id, CampaignID: campaignID, QueuedAt: time.Now()}))
+	}
+
+	popped, err := DequeueLeads("ws1", 3)
+	require.NoError(t, err)
+
+	var ids []string
+	for _, lead := range popped {
+		ids = append(ids, lead.LeadID)
+	}
+	assert.Equal(t, []string{"a1", "b1"}, ids)
+}
+
+// TestQueuedLeadIsExpired tests a lead without an expiry falls back to the
+// queued lead TTL
+func TestQueuedLeadIsExpired(t *testing.T) {
+	now := time.Now()
+
+	assert.True(t, QueuedLead{QueuedAt: now, ExpiresAt: now.Add(-time.Second)}.IsExpired(now))
+	assert.False(t, QueuedLead{QueuedAt: now, ExpiresAt: now.Add(time.Second)}.IsExpired(now))
+
+	assert.True(t, QueuedLead{QueuedAt: now.Add(-time.Hour)}.IsExpired(now))
+	assert.False(t, QueuedLead{QueuedAt: now}.IsExpired(now))
+}
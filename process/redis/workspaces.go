@@ -0,0 +1,124 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// scanBatchSize is the COUNT hint of each SCAN call
+const scanBatchSize = 100
+
+// scanKeys calls fn with every key matching pattern. A cluster is scanned on
+// each of its masters, as SCAN only visits the node it is sent to; fn is never
+// called concurrently.
+func scanKeys(ctx context.Context, pattern string, fn func(key string) error) error {
+	scan := func(ctx context.Context, client redis.Cmdable, fn func(key string) error) error {
+		iter := client.Scan(ctx, 0, pattern, scanBatchSize).Iterator()
+		for iter.Next(ctx) {
+			if err := fn(iter.Val()); err != nil {
+				return err
+			}
+		}
+
+		return iter.Err()
+	}
+
+	cluster, ok := rdb.(*redis.ClusterClient)
+	if !ok {
+		return scan(ctx, rdb, fn)
+	}
+
+	var mu sync.Mutex
+	return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		return scan(ctx, client, func(key string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return fn(key)
+		})
+	})
+}
+
+// GetActiveWorkspaces returns the workspaces registered by QueueLeadInLane
+func GetActiveWorkspaces() ([]string, error) {
+	workspaces, err := rdb.SMembers(ctx, activeWorkspacesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active workspaces: %v", err)
+	}
+
+	sort.Strings(workspaces)
+	return workspaces, nil
+}
+
+// UnregisterWorkspace removes a workspace from the active set once its queue
+// is empty. A workspace with queued leads stays registered.
+func UnregisterWorkspace(workspaceID string) error {
+	length, err := GetQueueLength(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	if length > 0 {
+		return nil
+	}
+
+	if err := rdb.SRem(ctx, activeWorkspacesKey, workspaceID).Err(); err != nil {
+		return fmt.Errorf("failed to unregister workspace %s: %v", workspaceID, err)
+	}
+
+	return nil
+}
+
+// GetWorkspaceQueues returns every workspace queue lane key, iterating the
+// SCAN cursor until the whole keyspace, of every cluster master, has been visited
+func GetWorkspaceQueues() ([]string, error) {
+	var queueKeys []string
+	err := scanKeys(ctx, queueKeyPrefix+"*", func(key string) error {
+		if _, _, ok := parseQueueKey(key); ok {
+			queueKeys = append(queueKeys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace queues: %v", err)
+	}
+
+	return queueKeys, nil
+}
+
+// ScanQueuedWorkspaces returns the workspaces that have a queue key, found by
+// scanning the keyspace. It also registers them as active, restoring the set
+// for queues written before it existed.
+func ScanQueuedWorkspaces() ([]string, error) {
+	keys, err := GetWorkspaceQueues()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var workspaces []string
+	for _, key := range keys {
+		workspaceID, _, _ := parseQueueKey(key)
+		if seen[workspaceID] {
+			continue
+		}
+		seen[workspaceID] = true
+		workspaces = append(workspaces, workspaceID)
+	}
+
+	if len(workspaces) > 0 {
+		members := make([]interface{}, len(workspaces))
+		for i, workspaceID := range workspaces {
+			members[i] = workspaceID
+		}
+		if err := rdb.SAdd(ctx, activeWorkspacesKey, members...).Err(); err != nil {
+			return nil, fmt.Errorf("failed to register queued workspaces: %v", err)
+		}
+	}
+
+	sort.Strings(workspaces)
+	return workspaces, nil
+}
@@ -6,40 +6,90 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nico-phil/process/logging"
 )
 
+var logger = logging.For("tz")
+
+// LoadStatus describes the last zip code data load
+type LoadStatus struct {
+	LoadedAt time.Time `json:"loaded_at"`
+	ZipCodes int       `json:"zip_codes"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// lastLoad is the status of the last LoadZipCodeData call, nil until then
+var lastLoad atomic.Pointer[LoadStatus]
+
+// LastLoad returns the status of the last zip code data load, nil when the
+// data has never been loaded
+func LastLoad() *LoadStatus {
+	return lastLoad.Load()
+}
+
 // LoadZipCodeData loads zip code data from the GeoNames database
 func LoadZipCodeData() (*ZipCodeCache, error) {
+	return recordLoad(loadZipCodeData(context.Background(), false))
+}
+
+// RefreshZipCodeData downloads the zip code data again, even when the local
+// copy looks up to date, and loads it
+func RefreshZipCodeData(ctx context.Context) (*ZipCodeCache, error) {
+	return recordLoad(loadZipCodeData(ctx, true))
+}
+
+// recordLoad stores the status of a load for LastLoad
+func recordLoad(cache *ZipCodeCache, err error) (*ZipCodeCache, error) {
+
+	status := &LoadStatus{LoadedAt: time.Now()}
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.ZipCodes = cache.Len()
+	}
+	lastLoad.Store(status)
+
+	return cache, err
+}
+
+// loadZipCodeData downloads the zip code data when it changed, or always when
+// force is set, and loads it
+func loadZipCodeData(ctx context.Context, force bool) (*ZipCodeCache, error) {
 	// Create the data directory if it doesn't exist
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %v", err)
 	}
 
 	// Check if we need to download the data
-	shouldDownload, err := shouldDownloadZipData()
-	if err != nil {
-		log.Printf("Error checking if zip data should be downloaded: %v", err)
-		log.Println("Will attempt to use existing data if available")
+	shouldDownload := force
+	if !force {
+		var err error
+		shouldDownload, err = shouldDownloadZipData()
+		if err != nil {
+			logger.Warn("failed to check if zip code data should be downloaded, using existing data if available", "error", err)
+		}
 	}
 
 	client := http.Client{}
 	if shouldDownload {
-		log.Println("Downloading zip code data...")
-		if err := downloadZipData(context.Background(), client, geoNamesZipURL, zipFilePath); err != nil {
+		logger.Info("downloading zip code data", "url", geoNamesZipURL())
+		if err := downloadZipData(ctx, client, geoNamesZipURL(), zipFilePath()); err != nil {
 			return nil, fmt.Errorf("failed to download zip data: %v", err)
 		}
 
-		log.Println("Extracting zip code data...")
+		logger.Info("extracting zip code data")
 		if err := extractZipData(); err != nil {
 			return nil, fmt.Errorf("failed to extract zip data: %v", err)
 		}
 	} else {
-		log.Println("Using existing zip code data")
+		logger.Info("using existing zip code data")
 	}
 
 	// Load the data into memory
@@ -50,12 +100,12 @@ func LoadZipCodeData() (*ZipCodeCache, error) {
 // shouldDownloadZipData checks if we need to download the zip data
 func shouldDownloadZipData() (bool, error) {
 	// If the CSV file doesn't exist, we definitely need to download
-	if _, err := os.Stat(csvFilePath); os.IsNotExist(err) {
+	if _, err := os.Stat(csvFilePath()); os.IsNotExist(err) {
 		return true, nil
 	}
 
 	// Check the file size on the server
-	resp, err := http.Head(geoNamesZipURL)
+	resp, err := http.Head(geoNamesZipURL())
 	if err != nil {
 		return false, fmt.Errorf("failed to check remote file size: %v", err)
 	}
@@ -72,7 +122,7 @@ func shouldDownloadZipData() (bool, error) {
 	}
 
 	// Get the local file size
-	fileInfo, err := os.Stat(zipFilePath)
+	fileInfo, err := os.Stat(zipFilePath())
 	if os.IsNotExist(err) {
 		return true, nil
 	} else if err != nil {
@@ -88,7 +138,7 @@ func shouldDownloadZipData() (bool, error) {
 // loadZipCodeDataFromCSV loads the zip code data from the CSV file
 func loadZipCodeDataFromCSV() (*ZipCodeCache, error) {
 	// Open the CSV file
-	file, err := os.Open(csvFilePath)
+	file, err := os.Open(csvFilePath())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open CSV file: %v", err)
 	}
@@ -155,7 +205,7 @@ func loadZipCodeDataFromCSV() (*ZipCodeCache, error) {
 		return nil, fmt.Errorf("error scanning CSV file: %v", err)
 	}
 
-	log.Printf("Loaded information for %d zip codes", len(cache.cache))
+	logger.Info("loaded zip codes", "count", len(cache.cache))
 	return cache, nil
 }
 
@@ -183,7 +233,7 @@ func determineTimeZone(longitude float64) string {
 // extractZipData extracts the zip code data from the downloaded zip file
 func extractZipData() error {
 	// Open the zip file
-	zipFile, err := zip.OpenReader(zipFilePath)
+	zipFile, err := zip.OpenReader(zipFilePath())
 	if err != nil {
 		return fmt.Errorf("failed to open zip file: %v", err)
 	}
@@ -210,7 +260,7 @@ func extractZipData() error {
 	defer usFileReader.Close()
 
 	// Create the output file
-	outFile, err := os.Create(csvFilePath)
+	outFile, err := os.Create(csvFilePath())
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %v", err)
 	}
@@ -2,21 +2,33 @@ package tz
 
 import (
 	"fmt"
+	"path/filepath"
 	"sync"
 	"time"
-)
 
-var (
-	// GeoNamesZipURL is the URL to download zip code data
-	geoNamesZipURL = "http://download.geonames.org/export/zip/US.zip"
-	// DataDir is the directory where we'll store the downloaded data
-	dataDir = "data"
-	// ZipFilePath is the path to the downloaded zip file
-	zipFilePath = dataDir + "/US.zip"
-	// CSVFilePath is the path to the extracted CSV file
-	csvFilePath = dataDir + "/US.txt"
+	"github.com/nico-phil/process/config"
 )
 
+// geoNamesZipURL is the URL to download zip code data
+func geoNamesZipURL() string {
+	return config.GetZipCodeURL()
+}
+
+// dataDir is the directory where we'll store the downloaded data
+func dataDir() string {
+	return config.GetZipCodeDataDir()
+}
+
+// zipFilePath is the path to the downloaded zip file
+func zipFilePath() string {
+	return filepath.Join(dataDir(), "US.zip")
+}
+
+// csvFilePath is the path to the extracted CSV file
+func csvFilePath() string {
+	return filepath.Join(dataDir(), "US.txt")
+}
+
 // ZipCode contains information about zipcode
 type ZipCodeInfo struct {
 	ZipCode   string
@@ -59,6 +71,13 @@ func (z *ZipCodeCache) Set(zipCode string, zipCodeInfo *ZipCodeInfo) {
 	z.setZipCode(zipCode, zipCodeInfo)
 }
 
+// Len returns the number of zip codes in the cache
+func (z *ZipCodeCache) Len() int {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	return len(z.cache)
+}
+
 // func(z *ZipCodeCache) GetLocalTime(zip)
 
 func GetLocalTimeAt(zipCodeCache *ZipCodeCache, zipCode string, utcTime time.Time) (time.Time, error) {
@@ -97,3 +116,29 @@ func LoadTimezoneWithFallback(timezone string) (*time.Location, error) {
 		return nil, fmt.Errorf("unknown time zone: %s", timezone)
 	}
 }
+
+// ParseLocalTimeAt parses a time in the local time zone of a zipcode. Values
+// with an explicit offset (RFC3339) are returned as is, values without one
+// ("2006-01-02T15:04") are read as wall clock time where the zipcode is.
+func ParseLocalTimeAt(zipCodeCache *ZipCodeCache, zipCode string, value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	info, ok := zipCodeCache.getZipcode(zipCode)
+	if !ok {
+		return time.Time{}, fmt.Errorf("zipcode not found in the cache")
+	}
+
+	loc, err := LoadTimezoneWithFallback(info.TimeZone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load timezone %v", err)
+	}
+
+	t, err := time.ParseInLocation("2006-01-02T15:04", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid local time %q: %v", value, err)
+	}
+
+	return t, nil
+}
@@ -181,6 +181,27 @@ func TestLoadTimezoneWithFallback(t *testing.T) {
 
 }
 
+// TestParseLocalTimeAt tests wall clock times are read in the zipcode time zone
+func TestParseLocalTimeAt(t *testing.T) {
+	zipCodeCache := NewZipCodeCache()
+	zipCodeCache.Set("94016", &ZipCodeInfo{ZipCode: "94016", TimeZone: "America/Los_Angeles"})
+
+	result, err := ParseLocalTimeAt(zipCodeCache, "94016", "2025-03-11T15:00")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2025, 3, 11, 22, 0, 0, 0, time.UTC), result.UTC())
+
+	// explicit offsets win over the zipcode
+	result, err = ParseLocalTimeAt(zipCodeCache, "94016", "2025-03-11T15:00:00Z")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2025, 3, 11, 15, 0, 0, 0, time.UTC), result.UTC())
+
+	_, err = ParseLocalTimeAt(zipCodeCache, "99999", "2025-03-11T15:00")
+	assert.NotNil(t, err)
+
+	_, err = ParseLocalTimeAt(zipCodeCache, "94016", "tuesday 3pm")
+	assert.NotNil(t, err)
+}
+
 // TestDownLoadZipData test downloadZipdata
 func TestDownLoadZipData_Sucess(t *testing.T) {
 